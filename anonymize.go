@@ -0,0 +1,64 @@
+package customerimporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Const "ANONYMIZED_HASH_LENGTH" signifies the number of hex characters kept from a hash replacing personal data.
+const ANONYMIZED_HASH_LENGTH = 16
+
+// Consts "ANONYMIZED_IPV*_PREFIX" signify the number of leading IP address bits kept by "Anonymize".
+const (
+	ANONYMIZED_IPV4_PREFIX = 24
+	ANONYMIZED_IPV6_PREFIX = 48
+)
+
+// Function "anonymizeValue" replaces a value with a truncated SHA-256 hash of it, keeping empty values empty.
+func anonymizeValue(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])[:ANONYMIZED_HASH_LENGTH]
+}
+
+// Function "anonymizeIP" zeroes host bits of an IP address, keeping only its network prefix.
+func anonymizeIP(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(ANONYMIZED_IPV4_PREFIX, 8*net.IPv4len))
+	}
+
+	return ip.Mask(net.CIDRMask(ANONYMIZED_IPV6_PREFIX, 8*net.IPv6len))
+}
+
+// Function "Anonymize" returns copies of customers safe for sharing: names and the local part of emails are replaced
+// with hashes, and host bits of IP addresses are zeroed. Domains, genders and countries are kept, so domain counts
+// of the result match the original.
+// Hashes are unsalted, so equal values map to equal hashes and common values can be guessed; treat it as pseudonymization.
+func Anonymize(customers []customer) []customer {
+	anonymized := make([]customer, 0, len(customers))
+
+	for _, c := range customers {
+		localPart, domain, _ := strings.Cut(string(c.Email), "@")
+
+		anonymized = append(anonymized, customer{
+			FirstName:  anonymizeValue(c.FirstName),
+			MiddleName: anonymizeValue(c.MiddleName),
+			LastName:   anonymizeValue(c.LastName),
+			Email:      email(anonymizeValue(localPart) + "@" + domain),
+			Gender:     c.Gender,
+			IPAddress:  anonymizeIP(c.IPAddress),
+			Country:    c.Country,
+		})
+	}
+
+	return anonymized
+}
@@ -0,0 +1,69 @@
+package customerimporter
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	customers := []customer{
+		{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.123"), Country: "US"},
+		{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com", Gender: female, IPAddress: net.ParseIP("2001:db8:1234:5678::1")},
+		{FirstName: "Jan", LastName: "Kowalski", Email: "jan@foo.org", Gender: male, IPAddress: net.ParseIP("10.0.0.1"), Country: "PL"},
+	}
+
+	anonymized := Anonymize(customers)
+
+	toProviders := func(customers []customer) []DomainProvider {
+		var providers []DomainProvider
+		for _, c := range customers {
+			providers = append(providers, c)
+		}
+		return providers
+	}
+
+	if got, want := CountDomains(toProviders(anonymized)), CountDomains(toProviders(customers)); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomains() of anonymized customers = %v, want %v", got, want)
+	}
+
+	for i, c := range anonymized {
+		original := customers[i]
+
+		if c.FirstName == original.FirstName || c.LastName == original.LastName {
+			t.Errorf("Anonymize() kept name %s %s", c.FirstName, c.LastName)
+		}
+
+		if strings.Contains(string(c.Email), strings.Split(string(original.Email), "@")[0]) {
+			t.Errorf("Anonymize() kept local part of email %s", c.Email)
+		}
+
+		if !c.Email.isValid() {
+			t.Errorf("Anonymize() produced invalid email %s", c.Email)
+		}
+
+		if c.Gender != original.Gender {
+			t.Errorf("Anonymize() changed gender to %v, want %v", c.Gender, original.Gender)
+		}
+
+		if c.Country != original.Country {
+			t.Errorf("Anonymize() changed country to %q, want %q", c.Country, original.Country)
+		}
+	}
+
+	if anonymized[0].LastName != anonymized[1].LastName {
+		t.Errorf("Anonymize() hashed equal last names differently")
+	}
+
+	wantIPs := []string{"192.168.1.0", "2001:db8:1234::", "10.0.0.0"}
+	for i, want := range wantIPs {
+		if got := anonymized[i].IPAddress.String(); got != want {
+			t.Errorf("Anonymize() IP address = %v, want %v", got, want)
+		}
+	}
+
+	if customers[0].FirstName != "John" {
+		t.Errorf("Anonymize() modified the input customers")
+	}
+}
@@ -0,0 +1,102 @@
+package customerimporter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCustomersCountry(t *testing.T) {
+	schema := &Schema{Columns: []ColumnSpec{
+		{Name: "mail", Field: FIELD_EMAIL, Required: true},
+		{Name: "country_code", Field: FIELD_COUNTRY},
+	}}
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Valid codes normalized",
+			input:   "mail,country_code\nfirst@example.com,PL\nsecond@example.com, de \nthird@example.com,\n",
+			opts:    ReadOptions{Schema: schema},
+			want:    []string{"PL", "DE", ""},
+			wantErr: false,
+		},
+		{
+			name:    "Unknown code accepted without strict option",
+			input:   "mail,country_code\nfirst@example.com,XX\n",
+			opts:    ReadOptions{Schema: schema},
+			want:    []string{"XX"},
+			wantErr: false,
+		},
+		{
+			name:    "Unknown code rejected with strict option",
+			input:   "mail,country_code\nfirst@example.com,PL\nsecond@example.com,XX\n",
+			opts:    ReadOptions{Schema: schema, StrictCountry: true},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed code rejected",
+			input:   "mail,country_code\nfirst@example.com,POL\n",
+			opts:    ReadOptions{Schema: schema},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Mapped by header ignores country",
+			input:   "first_name,last_name,email,ip_address,country\nFirst,Last,first@example.com,192.168.1.1,Poland\n",
+			opts:    ReadOptions{MapColumnsByHeader: true, StrictCountry: true},
+			want:    []string{""},
+			wantErr: false,
+		},
+		{
+			name:    "Fixed column order has no country",
+			input:   "first_name,last_name,email,gender,ip_address,country\nFirst,Last,first@example.com,male,192.168.1.1,XX\n",
+			opts:    ReadOptions{StrictCountry: true},
+			want:    []string{""},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customers, err := ReadCustomersFromCSVWithOptions(strings.NewReader(tt.input), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, c := range customers {
+				got = append(got, c.Country)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCustomersFromCSVWithOptions() countries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCustomersCountryParseError(t *testing.T) {
+	schema := &Schema{Columns: []ColumnSpec{
+		{Name: "email", Field: FIELD_EMAIL, Required: true},
+		{Name: "country", Field: FIELD_COUNTRY},
+	}}
+	input := "email,country\nfirst@example.com,PL\nsecond@example.com,ZZ\n"
+
+	_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{Schema: schema, StrictCountry: true})
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want *ParseError", err)
+	}
+	if parseErr.Line != 3 || parseErr.Field != FIELD_COUNTRY || parseErr.Value != "ZZ" {
+		t.Errorf("ReadCustomersFromCSVWithOptions() error at line %d field %q value %q, want line 3 field %q value %q",
+			parseErr.Line, parseErr.Field, parseErr.Value, FIELD_COUNTRY, "ZZ")
+	}
+}
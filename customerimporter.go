@@ -3,23 +3,305 @@
 package customerimporter
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
+	"iter"
 	"net"
+	"net/mail"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/net/publicsuffix"
 )
 
 // Const "CSV_FIRST_LINE_NUMBER" signifies first line of an open CSV file.
 const CSV_FIRST_LINE_NUMBER = 1
 
+// Const "CSV_FIELD_COUNT" signifies the number of fields expected in a single line of CSV file.
+const CSV_FIELD_COUNT = 5
+
 // Const "MIN_CHUNK_SIZE" signifies the minimum size for a chunk
 const MIN_CHUNK_SIZE = 1
 
+// Const "MAX_CONCURRENT_WORKERS" signifies the maximum number of goroutines "CountDomainsConcurrent" runs at once,
+// regardless of the number of workers asked for. Use "CountDomainsConcurrentLimit" to configure a different limit.
+const MAX_CONCURRENT_WORKERS = 256
+
+// Const "DELIMITER_SAMPLE_SIZE" signifies how many bytes are peeked from the input to detect the delimiter.
+const DELIMITER_SAMPLE_SIZE = 4096
+
+// Variable "candidateDelimiters" lists delimiters recognized by "DetectDelimiter", in order of preference on ties.
+var candidateDelimiters = []rune{',', ';', '\t'}
+
+// Variable "gzipMagic" holds the leading bytes identifying a gzip compressed stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Type "ReadOptions" groups optional settings for reading customer data from CSV file.
+// Zero value reproduces the default behavior of "ReadCustomersFromCSV" and "ReadAndCountDomainsFromCSV".
+type ReadOptions struct {
+	// Delimiter separates fields in a line, comma is used when left empty.
+	Delimiter rune
+	// AutoDelimiter detects the delimiter from the header line, taking precedence over "Delimiter".
+	AutoDelimiter bool
+	// PrevalidateFirstRow checks the first data line against the expected schema before reading the rest of the file.
+	PrevalidateFirstRow bool
+	// TrimLeadingSpace ignores spaces before a field, outside of quotes, e.g. after the delimiter in "John, Doe".
+	// Spaces inside quotes are kept, see "TrimFieldSpace" for them.
+	TrimLeadingSpace bool
+	// TrimFieldSpace trims whitespace around every data field once it is parsed, including whitespace inside quotes,
+	// e.g. "  John  " in `"  John  "`. Fields are trimmed before "Transform" is applied.
+	TrimFieldSpace bool
+	// RecordSeparator, when set, ends lines instead of the standard line endings understood by "encoding/csv",
+	// e.g. "\r" for old Mac exports or "\x1e". It is replaced with "\n" before parsing, including inside quoted fields.
+	RecordSeparator string
+	// Comment, when set, marks lines starting with this character as comments to be ignored.
+	Comment rune
+	// IgnoreFooter ignores the last line of the file if its number of fields differs from the header, e.g. "Total: 1000".
+	IgnoreFooter bool
+	// MapColumnsByHeader locates customer fields by column names in CSV header instead of their fixed order,
+	// also filling optional fields like middle name. Unknown columns are ignored.
+	MapColumnsByHeader bool
+	// SkipInvalid skips lines with invalid customer data instead of stopping at the first one.
+	// Structural errors still stop reading, see "isStructuralError".
+	SkipInvalid bool
+	// MinValidRatio fails reading with "ErrLowValidRatio" when, with "SkipInvalid" set, the fraction of valid lines is below it,
+	// e.g. 0.9 when more than 10% of lines are invalid. It is checked once the whole file is read, 0 disables it.
+	MinValidRatio float64
+	// Transform, when set, is applied to every data line before it is validated, e.g. to replace "N/A" with empty values.
+	// It may modify and return the line it is given, which must not be retained after it returns. Headers are not transformed.
+	Transform func([]string) []string
+	// Validators are applied in order to every parsed customer, the first error rejects the line with a "ParseError".
+	Validators []CustomerValidatorFunc
+	// MaxFieldLen rejects lines with any field longer than given number of bytes, 0 means unlimited.
+	// It stops oversized values from being retained, but a single line is still read into memory whole.
+	MaxFieldLen int
+	// AllowDisplayName accepts emails in RFC 5322 address form, e.g. "John Doe <john@example.com>", keeping only the address.
+	// Values that do not parse as an address are validated as they are.
+	AllowDisplayName bool
+	// StrictDomain additionally requires email domains to be well-formed with at least two labels, e.g. "example.com",
+	// rejecting single-label domains like "localhost" accepted by "LenientDomain" or "EmailPattern".
+	StrictDomain bool
+	// EmailPattern replaces the built-in "emailRegex" used to validate emails, nil means the default.
+	// Domains are still extracted after "@", so the pattern should require it.
+	EmailPattern *regexp.Regexp
+	// LenientDomain accepts technically invalid emails, e.g. without top level domain like "user@localhost",
+	// as long as they have a domain after "@", so that domain is still counted.
+	LenientDomain bool
+	// StripWWW removes a leading "www." from domains before counting them.
+	StripWWW bool
+	// CountOnly speeds up counting domains by validating lines without building full customer data.
+	// Counts are identical to the default path. It is ignored when "Validators" are set, as they need full customer data.
+	CountOnly bool
+	// FastCSV speeds up "CountOnly" further by splitting simple lines on the delimiter instead of parsing them with "encoding/csv".
+	// From the first line with a quote on, the rest of the file is parsed with "encoding/csv", so counts stay identical.
+	// It is ignored without "CountOnly", with "TrimLeadingSpace" or with a delimiter or comment character that is not ASCII.
+	FastCSV bool
+	// Schema, when set, maps columns by header names as it describes, taking precedence over "MapColumnsByHeader".
+	Schema *Schema
+	// ResolvedColumns, when not nil, is cleared and filled with header column names that customer fields were read from,
+	// keyed by "FIELD_*" names. It lets callers confirm the mapping found with "MapColumnsByHeader".
+	ResolvedColumns map[string]string
+	// ValidateUTF8 rejects lines with any field that is not valid UTF-8, which would otherwise break e.g. JSON output.
+	ValidateUTF8 bool
+	// SanitizeUTF8 replaces invalid UTF-8 sequences with U+FFFD instead of rejecting the line, it implies "ValidateUTF8".
+	SanitizeUTF8 bool
+	// DomainFilter, when set, is called with every domain about to be counted and excludes it from counts when it returns false,
+	// e.g. to apply a denylist. It is called for each line rather than once, so logic it consults can be swapped while reading
+	// and applies to the following lines. Filtered lines are still valid and not reported as skipped.
+	DomainFilter func(domain string) bool
+	// ExcludeRoleAccounts leaves out customers with role account emails, e.g. "noreply@example.com", from domain counts,
+	// so they reflect real users. Such lines are still valid and not reported as skipped.
+	ExcludeRoleAccounts bool
+	// RoleAccounts lists lowercase local parts treated as role accounts by "ExcludeRoleAccounts", "DefaultRoleAccounts" when nil.
+	RoleAccounts map[string]bool
+	// StrictCountry rejects country codes that are not officially assigned ISO 3166-1 alpha-2 codes, e.g. "XX".
+	// Without it only the two letter form is checked. Empty codes are accepted either way.
+	StrictCountry bool
+	// EstimatedRows, when positive, preallocates room for that many customers and domains, avoiding repeated growth
+	// when the number of lines is roughly known. It is only a hint, any number of lines is still read. The domain map
+	// is sized as if every line had a unique domain, so it holds more memory than needed for inputs with few domains.
+	EstimatedRows int
+	// Stats, when set, is filled with statistics gathered while reading.
+	Stats *ReadStats
+}
+
+// Type "ReadStats" holds statistics gathered while reading customer data from CSV file.
+type ReadStats struct {
+	// Lines is the number of data lines processed, excluding headers.
+	Lines int
+	// Skipped is the number of lines skipped because of invalid data, see "ReadOptions.SkipInvalid".
+	Skipped int
+	// InvalidEmails is the number of skipped lines with an invalid email, even when another field rejected them first.
+	InvalidEmails int
+	// Bytes is the number of bytes read from the input, e.g. for throughput reporting. The CSV reader reads ahead,
+	// so when reading stops early it may include bytes past the last processed line.
+	Bytes int64
+}
+
+// Type "countingReader" wraps a reader, adding the number of bytes read from it to "count".
+type countingReader struct {
+	r     io.Reader
+	count *int64
+}
+
+// Method "Read" reads from the wrapped reader, satisfying "io.Reader" interface.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	*cr.count += int64(n)
+	return n, err
+}
+
+// Const "RECORD_SEPARATOR_CHUNK_SIZE" signifies how many bytes "recordSeparatorReader" reads from the input at once.
+const RECORD_SEPARATOR_CHUNK_SIZE = 32 * 1024
+
+// Type "recordSeparatorReader" wraps a reader, replacing every "separator" with "\n", see "ReadOptions.RecordSeparator".
+// Separators are matched left to right like in "bytes.ReplaceAll", also when they are split between reads.
+type recordSeparatorReader struct {
+	r         io.Reader
+	separator []byte
+	chunk     []byte
+	// pending holds read bytes that may start a separator completed by the next read
+	pending []byte
+	out     []byte
+	err     error
+}
+
+// Function "newRecordSeparatorReader" returns a reader replacing "separator" read from "r" with "\n".
+func newRecordSeparatorReader(r io.Reader, separator string) *recordSeparatorReader {
+	return &recordSeparatorReader{r: r, separator: []byte(separator), chunk: make([]byte, RECORD_SEPARATOR_CHUNK_SIZE)}
+}
+
+func (sr *recordSeparatorReader) Read(p []byte) (int, error) {
+	for len(sr.out) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+
+		n, err := sr.r.Read(sr.chunk)
+		sr.err = err
+		raw := append(sr.pending, sr.chunk[:n]...)
+
+		out := sr.out[:0]
+		i := 0
+		for i < len(raw) {
+			rest := raw[i:]
+			if bytes.HasPrefix(rest, sr.separator) {
+				out = append(out, '\n')
+				i += len(sr.separator)
+				continue
+			}
+			if err == nil && len(rest) < len(sr.separator) && bytes.HasPrefix(sr.separator, rest) {
+				break
+			}
+			out = append(out, raw[i])
+			i++
+		}
+
+		sr.out = out
+		sr.pending = append(raw[:0], raw[i:]...)
+	}
+
+	n := copy(p, sr.out)
+	sr.out = sr.out[n:]
+	return n, nil
+}
+
+// Method "EmailValidityRate" returns the fraction of processed lines that had a valid email, or 0 if no lines were processed.
+func (s ReadStats) EmailValidityRate() float64 {
+	if s.Lines == 0 {
+		return 0
+	}
+
+	return float64(s.Lines-s.InvalidEmails) / float64(s.Lines)
+}
+
+// Function "DetectDelimiter" sniffs the first line of a sample for the most likely delimiter among comma, semicolon and tab.
+// Delimiters inside quoted fields are ignored. It falls back to comma when none of them is found.
+func DetectDelimiter(sample []byte) rune {
+	counts := make(map[rune]int)
+	inQuotes := false
+
+	for _, r := range string(sample) {
+		if r == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if inQuotes {
+			continue
+		}
+		if r == '\n' || r == '\r' {
+			break
+		}
+		counts[r]++
+	}
+
+	delimiter := candidateDelimiters[0]
+	for _, candidate := range candidateDelimiters[1:] {
+		if counts[candidate] > counts[delimiter] {
+			delimiter = candidate
+		}
+	}
+
+	return delimiter
+}
+
+// Function "newCSVReader" creates a CSV reader configured according to "ReadOptions".
+// With "AutoDelimiter" set, it peeks a sample of the input without consuming it.
+func newCSVReader(r io.Reader, opts ReadOptions) (*csv.Reader, error) {
+	r, delimiter, err := prepareCSVInput(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	// Fields are copied out to "customer" struct, so the record slice can be reused between lines
+	reader.ReuseRecord = true
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+	reader.Comment = opts.Comment
+	reader.TrimLeadingSpace = opts.TrimLeadingSpace
+
+	return reader, nil
+}
+
+// Function "prepareCSVInput" wraps "r" with readers needed by "ReadOptions" before CSV parsing and returns the delimiter to use,
+// detecting it when "ReadOptions.AutoDelimiter" is set. Zero delimiter means the default comma.
+func prepareCSVInput(r io.Reader, opts ReadOptions) (io.Reader, rune, error) {
+	delimiter := opts.Delimiter
+
+	if opts.RecordSeparator != "" && opts.RecordSeparator != "\n" {
+		r = newRecordSeparatorReader(r, opts.RecordSeparator)
+	}
+
+	if opts.AutoDelimiter {
+		bufferedReader := bufio.NewReaderSize(r, DELIMITER_SAMPLE_SIZE)
+		sample, err := bufferedReader.Peek(DELIMITER_SAMPLE_SIZE)
+		if err != nil && err != io.EOF {
+			return nil, 0, fmt.Errorf("error detecting delimiter: %w", err)
+		}
+
+		delimiter = DetectDelimiter(sample)
+		r = bufferedReader
+	}
+
+	return r, delimiter, nil
+}
+
 // Function "isHeaderLine" checks for CSV header repetition in a single CSV file.
 // Could also be a generic function to compare two string slices.
 func isHeaderLine(a, b []string) bool {
@@ -40,18 +322,110 @@ func isHeaderLine(a, b []string) bool {
 type email string
 
 // Variable "emailRegex" is precompiled regex that checks for email correctness.
-var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+// Domain can be written as fully qualified, with a trailing dot.
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}\.?$`)
 
 // Method "isValid" checks for email correctness using precompiled regex value "emailRegex".
 func (e email) isValid() bool {
 	return emailRegex.MatchString(string(e))
 }
 
-// Method "extractDomain" extracts the domain part from an email address.
-// It assumes the email address is valid.
+// Method "isValidWithOptions" works like "isValid", checking against "ReadOptions.EmailPattern" instead when it is set.
+func (e email) isValidWithOptions(opts ReadOptions) bool {
+	if opts.EmailPattern != nil {
+		return opts.EmailPattern.MatchString(string(e))
+	}
+
+	return e.isValid()
+}
+
+// Method "hasDomain" checks whether an email address has a non-empty part after "@", regardless of its validity.
+func (e email) hasDomain() bool {
+	_, domain, found := strings.Cut(string(e), "@")
+	return found && domain != ""
+}
+
+// Method "hasStrictDomain" checks whether an email domain has at least two labels ending with an alphabetic TLD of 2 or more
+// characters, e.g. "example.com". Labels must be non-empty, made of letters, digits and hyphens, not starting or ending with a hyphen.
+func (e email) hasStrictDomain() bool {
+	_, domain, found := strings.Cut(string(e), "@")
+	if !found {
+		return false
+	}
+
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	tld := labels[len(labels)-1]
+	if len(tld) < 2 {
+		return false
+	}
+	for _, r := range tld {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Method "extractDomain" extracts the domain part from an email address, normalized to lowercase and without
+// the trailing dot of a fully qualified domain, so equivalent spellings are counted together.
+// It assumes the email address is valid, returning an empty string when there is no "@".
 func (e email) extractDomain() string {
-	parts := strings.Split(string(e), "@")
-	return parts[1]
+	_, domain, _ := strings.Cut(string(e), "@")
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// Variable "DefaultRoleAccounts" lists common local parts of role and system accounts, used by "ReadOptions.ExcludeRoleAccounts"
+// when no set is given.
+var DefaultRoleAccounts = map[string]bool{
+	"noreply":       true,
+	"no-reply":      true,
+	"donotreply":    true,
+	"do-not-reply":  true,
+	"postmaster":    true,
+	"hostmaster":    true,
+	"webmaster":     true,
+	"mailer-daemon": true,
+	"abuse":         true,
+	"admin":         true,
+	"administrator": true,
+	"root":          true,
+	"info":          true,
+	"support":       true,
+}
+
+// Method "isRoleAccount" checks whether the local part of an email address, lowercased and without "+" subaddress,
+// is in "roleAccounts", e.g. "NoReply+billing@example.com" for "noreply".
+func (e email) isRoleAccount(roleAccounts map[string]bool) bool {
+	localPart, _, _ := strings.Cut(string(e), "@")
+	localPart, _, _ = strings.Cut(localPart, "+")
+	return roleAccounts[strings.ToLower(localPart)]
+}
+
+// Method "extractDomainWithOptions" works like "extractDomain", additionally normalizing the domain according to "ReadOptions".
+func (e email) extractDomainWithOptions(opts ReadOptions) string {
+	domain := e.extractDomain()
+
+	if opts.StripWWW {
+		domain = strings.TrimPrefix(domain, "www.")
+	}
+
+	return domain
 }
 
 // Type "gender" contains all valid genders as enum value.
@@ -62,19 +436,92 @@ const (
 	male
 	female
 	transgender
-	// and more...
+	// and more, see "RegisterGender"
 )
 
-// Function "parseGender" checks whether "gender" value is on the list of valid genders, otherwise returns "unknown" as value.
-func parseGender(genderStr string) gender {
-	var genderMap = map[string]gender{
+// Variables "genderMap" and "registeredGenderNames" hold genders recognized by "parseGender", including those added
+// with "RegisterGender", guarded by "gendersMu" as they can be changed at runtime.
+var (
+	gendersMu sync.RWMutex
+	genderMap = map[string]gender{
 		"male":        male,
 		"female":      female,
 		"transgender": transgender,
+		"m":           male,
+		"f":           female,
+		"t":           transgender,
+		"1":           male,
+		"2":           female,
+	}
+	registeredGenderNames = map[gender]string{}
+	nextRegisteredGender  = transgender + 1
+)
+
+// Function "normalizeGenderName" prepares a gender name for lookup, converting it to lowercase, trimming surrounding whitespace
+// and collapsing inner whitespace to a single space.
+func normalizeGenderName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Function "RegisterGender" adds a gender recognized by "parseGender" beyond the built-in ones, e.g. "non-binary",
+// assigning it the next free value, and returns it. Names are matched like in "parseGender", registering a name
+// that is already recognized returns its existing value. An empty name or "unknown" itself is "unknown". It is safe for concurrent use,
+// but genders registered while a file is read may not apply to lines already read.
+func RegisterGender(name string) gender {
+	name = normalizeGenderName(name)
+	if name == "" || name == "unknown" {
+		return unknown
+	}
+
+	gendersMu.Lock()
+	defer gendersMu.Unlock()
+
+	if existing, exists := genderMap[name]; exists {
+		return existing
 	}
 
-	genderStr = strings.ToLower(genderStr)
+	registered := nextRegisteredGender
+	nextRegisteredGender++
+	genderMap[name] = registered
+	registeredGenderNames[registered] = name
+
+	return registered
+}
+
+// Method "String" returns the lowercase name of gender, as accepted by "parseGender".
+func (g gender) String() string {
+	switch g {
+	case unknown:
+		return "unknown"
+	case male:
+		return "male"
+	case female:
+		return "female"
+	case transgender:
+		return "transgender"
+	}
+
+	gendersMu.RLock()
+	defer gendersMu.RUnlock()
+
+	if name, exists := registeredGenderNames[g]; exists {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Function "parseGender" checks whether "gender" value is on the list of valid genders, otherwise returns "unknown" as value.
+// Besides full names, single letter codes ("m", "f", "t"), ISO/IEC 5218 numeric codes ("1", "2") and genders added with
+// "RegisterGender" are recognized.
+// Surrounding whitespace is trimmed and inner whitespace collapsed to a single space, but words are not joined,
+// so "trans gender" is "unknown" rather than a guess at "transgender".
+func parseGender(genderStr string) gender {
+	genderStr = normalizeGenderName(genderStr)
+
+	gendersMu.RLock()
 	val, exists := genderMap[genderStr]
+	gendersMu.RUnlock()
 	if exists {
 		return val
 	}
@@ -83,45 +530,135 @@ func parseGender(genderStr string) gender {
 }
 
 // Type "customer" reflects the expected structure of a customer data in CSV file.
+// Optional fields are only filled when their column is found by "ReadOptions.MapColumnsByHeader" or "ReadOptions.Schema".
 type customer struct {
-	FirstName string
-	LastName  string
-	Email     email
-	Gender    gender
-	IPAddress net.IP
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Email      email
+	Gender     gender
+	IPAddress  net.IP
+	// Country holds an uppercase ISO 3166-1 alpha-2 code, e.g. "PL", empty when unknown.
+	Country string
 }
 
 // Interface "DomainProvider" is for types that can provide a domain string.
+// "CountDomains" and "CountDomainsConcurrent" accept any mix of implementations in a single slice,
+// domains returned by different types are counted together by their exact string value.
+// Implementations used with "CountDomainsConcurrent" must be safe to call from multiple goroutines.
 type DomainProvider interface {
 	GetDomain() string
 }
 
+// Method "GetDomain" returns the domain part of customer's email, satisfying "DomainProvider" interface.
 func (c customer) GetDomain() string {
 	return c.Email.extractDomain()
 }
 
+// Type "DomainString" is a domain that is already extracted, e.g. read from a list of domains rather than emails,
+// satisfying "DomainProvider" interface so it can be counted without parsing an email.
+type DomainString string
+
+// Method "GetDomain" returns the domain normalized like domains of customer's email, lowercase and without trailing dot.
+func (d DomainString) GetDomain() string {
+	return strings.TrimSuffix(strings.ToLower(string(d)), ".")
+}
+
+// Method "IPString" returns customer's IP address in canonical form, dotted-quad for IPv4, or empty string if there is none.
+func (c customer) IPString() string {
+	if c.IPAddress == nil {
+		return ""
+	}
+
+	return c.IPAddress.String()
+}
+
+// Method "RegistrableDomain" returns the domain registered under a public suffix, e.g. "example.co.uk" for "foo.example.co.uk".
+// Unlike "GetDomain", which returns the full host, it uses the public suffix list to drop subdomains.
+func (c customer) RegistrableDomain() (string, error) {
+	return publicsuffix.EffectiveTLDPlusOne(c.GetDomain())
+}
+
+// Type "CustomerSortField" selects the customer field "SortCustomers" orders by.
+type CustomerSortField int
+
+const (
+	SORT_BY_LAST_NAME CustomerSortField = iota
+	SORT_BY_FIRST_NAME
+	SORT_BY_EMAIL_DOMAIN
+	SORT_BY_IP_ADDRESS
+)
+
+// Function "SortCustomers" sorts customers in place by the chosen field, comparing names and domains byte-wise
+// and IP addresses numerically in their 16-byte form, with missing addresses first.
+// The sort is stable, so customers with equal fields keep their relative order.
+func SortCustomers(customers []customer, by CustomerSortField) {
+	var compare func(a, b customer) int
+
+	switch by {
+	case SORT_BY_LAST_NAME:
+		compare = func(a, b customer) int { return strings.Compare(a.LastName, b.LastName) }
+	case SORT_BY_FIRST_NAME:
+		compare = func(a, b customer) int { return strings.Compare(a.FirstName, b.FirstName) }
+	case SORT_BY_EMAIL_DOMAIN:
+		compare = func(a, b customer) int { return strings.Compare(a.GetDomain(), b.GetDomain()) }
+	case SORT_BY_IP_ADDRESS:
+		compare = func(a, b customer) int { return bytes.Compare(a.IPAddress.To16(), b.IPAddress.To16()) }
+	default:
+		return
+	}
+
+	slices.SortStableFunc(customers, compare)
+}
+
 // Type "domainCount" groups domain name and its occurences in a CSV file in a single struct.
 type domainCount struct {
-	Domain string
-	Count  int
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
 }
 
 // Function "sortDomainCounts" translates a map of domains and its occurences to a "domainCount" slice and
-// sorts it by the count.
+// sorts it by the count. Domains with equal count are sorted alphabetically, so the result is deterministic.
 func sortDomainCounts(domainCounts map[string]int) []domainCount {
-	var domainCountSlice []domainCount
+	domainCountSlice := make([]domainCount, 0, len(domainCounts))
 
 	for domain, count := range domainCounts {
 		domainCountSlice = append(domainCountSlice, domainCount{Domain: domain, Count: count})
 	}
 
-	sort.Slice(domainCountSlice, func(i, j int) bool {
-		return domainCountSlice[i].Count > domainCountSlice[j].Count
-	})
+	SortDomainCountsFunc(domainCountSlice, byCountDescending)
 
 	return domainCountSlice
 }
 
+// Function "byCountDescending" orders domain counts from the most to the least occurences, alphabetically on equal counts.
+func byCountDescending(a, b domainCount) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Domain < b.Domain
+}
+
+// Function "SortDomainCountsFunc" sorts domain counts in place using "less" to compare them, e.g. by domain length.
+// The sort is stable, so counts that "less" considers equal keep their relative order.
+func SortDomainCountsFunc(counts []domainCount, less func(a, b domainCount) bool) {
+	sort.SliceStable(counts, func(i, j int) bool {
+		return less(counts[i], counts[j])
+	})
+}
+
+// Function "DomainCountsToMap" translates a "domainCount" slice to a map of domains and their occurences,
+// being the inverse of "sortDomainCounts". Counts of a domain listed more than once are summed.
+func DomainCountsToMap(counts []domainCount) map[string]int {
+	domainCounts := make(map[string]int, len(counts))
+
+	for _, dc := range counts {
+		domainCounts[dc.Domain] += dc.Count
+	}
+
+	return domainCounts
+}
+
 // Function "CountDomains" returns a sorted slice of "domainCount" type, with unique domain names and their respective count.
 func CountDomains(providers []DomainProvider) []domainCount {
 	domainCounts := make(map[string]int)
@@ -134,168 +671,1295 @@ func CountDomains(providers []DomainProvider) []domainCount {
 	return sortDomainCounts(domainCounts)
 }
 
-// Function "CountDomainsConcurrent" returns a sorted slice of "domainCount" type, with unique domain names and their respective count.
-// It utilizes goroutines to speed up the process for larger datasets.
-func CountDomainsConcurrent(providers []DomainProvider) []domainCount {
-	domainCounts := make(map[string]int)
+// Function "UniqueDomains" returns unique domain names of providers, sorted alphabetically.
+func UniqueDomains(providers []DomainProvider) []string {
+	seen := make(map[string]struct{})
+	domains := []string{}
+
+	for _, provider := range providers {
+		domain := provider.GetDomain()
+		if _, exists := seen[domain]; exists {
+			continue
+		}
+
+		seen[domain] = struct{}{}
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+
+	return domains
+}
+
+// Type "DomainCounter" accumulates occurences of domains, allowing counts to be built incrementally across many inputs.
+// Zero value is ready to use. It is not safe for concurrent use.
+type DomainCounter struct {
+	domainCounts map[string]int
+}
+
+// Function "NewDomainCounter" returns an empty "DomainCounter".
+func NewDomainCounter() *DomainCounter {
+	return &DomainCounter{domainCounts: make(map[string]int)}
+}
+
+// Function "newDomainCounterWithSize" returns an empty "DomainCounter" with room for "size" domains, see "ReadOptions.EstimatedRows".
+func newDomainCounterWithSize(size int) *DomainCounter {
+	return &DomainCounter{domainCounts: make(map[string]int, max(size, 0))}
+}
+
+// Method "Add" increments the count of given domain.
+func (dc *DomainCounter) Add(domain string) {
+	if dc.domainCounts == nil {
+		dc.domainCounts = make(map[string]int)
+	}
+	dc.domainCounts[domain]++
+}
 
+// Method "AddCount" increases the count of given domain by "count", e.g. to restore previously computed counts.
+func (dc *DomainCounter) AddCount(domain string, count int) {
+	if dc.domainCounts == nil {
+		dc.domainCounts = make(map[string]int)
+	}
+	dc.domainCounts[domain] += count
+}
+
+// Method "Counts" returns a sorted slice of "domainCount" type with domains counted so far.
+func (dc *DomainCounter) Counts() []domainCount {
+	return sortDomainCounts(dc.domainCounts)
+}
+
+// Method "Len" returns the number of unique domains counted so far.
+func (dc *DomainCounter) Len() int {
+	return len(dc.domainCounts)
+}
+
+// Method "Reset" clears all counts, keeping the allocated map so the counter can be reused without reallocation.
+func (dc *DomainCounter) Reset() {
+	clear(dc.domainCounts)
+}
+
+// Function "CountDomainsConcurrent" returns a sorted slice of "domainCount" type, with unique domain names and their respective count.
+// It utilizes goroutines to speed up the process for larger datasets. The result is identical to "CountDomains"
+// for any input, regardless of goroutine scheduling. A panic in "GetDomain" of any provider is recovered and returned
+// as an error wrapping "ErrProviderPanic", with no counts, instead of crashing the program.
+func CountDomainsConcurrent(providers []DomainProvider) ([]domainCount, error) {
 	// Optimize to machine
-	numCores := runtime.NumCPU()
+	return countDomainsConcurrent(providers, runtime.NumCPU())
+}
+
+// Const "DOMAIN_COUNT_SHARDS" signifies the number of separately locked maps "countDomainsConcurrent" merges counts into.
+const DOMAIN_COUNT_SHARDS = 64
+
+// Type "domainCountShard" holds counts of domains hashed to one shard, guarded by its own lock.
+type domainCountShard struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// Function "CountDomainsConcurrentWorkers" works like "CountDomainsConcurrent" on a fixed number of workers instead of one per CPU,
+// so chunk boundaries are the same on every machine, e.g. to reproduce edge cases. Less than one worker means one.
+// No more than "MAX_CONCURRENT_WORKERS" of them run at once, the rest wait for a running one to finish.
+func CountDomainsConcurrentWorkers(providers []DomainProvider, workers int) ([]domainCount, error) {
+	return countDomainsConcurrent(providers, workers)
+}
+
+// Function "CountDomainsConcurrentLimit" works like "CountDomainsConcurrentWorkers", running no more than "maxGoroutines"
+// workers at once instead of "MAX_CONCURRENT_WORKERS", e.g. to bound resources used by providers. Less than one means one.
+func CountDomainsConcurrentLimit(providers []DomainProvider, workers int, maxGoroutines int) ([]domainCount, error) {
+	return countDomainsConcurrentLimit(providers, workers, maxGoroutines)
+}
+
+// Function "countDomainsConcurrent" splits providers into at most "numWorkers" chunks of equal size, the last one
+// holding the remainder, and counts each chunk in a separate goroutine. Workers merge their counts into
+// "DOMAIN_COUNT_SHARDS" shards picked by hash of the domain, so merges of different workers rarely wait for each other.
+// Panics of workers are recovered and reported through a channel, all of them are joined into the returned error.
+func countDomainsConcurrent(providers []DomainProvider, numWorkers int) ([]domainCount, error) {
+	return countDomainsConcurrentLimit(providers, numWorkers, MAX_CONCURRENT_WORKERS)
+}
+
+// Function "countDomainsConcurrentLimit" works like "countDomainsConcurrent", running at most "maxGoroutines" workers at once.
+// A buffered channel serves as semaphore, acquired before a worker goroutine starts, so the number of goroutines
+// stays bounded for any input. Less than one means one.
+func countDomainsConcurrentLimit(providers []DomainProvider, numWorkers int, maxGoroutines int) ([]domainCount, error) {
+	seed := maphash.MakeSeed()
+	shards := make([]domainCountShard, DOMAIN_COUNT_SHARDS)
+	for i := range shards {
+		shards[i].counts = make(map[string]int)
+	}
+
 	totalProviders := len(providers)
-	chunkSize := totalProviders / numCores
+	// no more workers than providers, so neither the rounding below nor the channels grow with a huge worker count
+	numWorkers = min(numWorkers, totalProviders)
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	// Round up, so the remainder is spread over chunks instead of spawning extra goroutines
+	chunkSize := (totalProviders + numWorkers - 1) / numWorkers
 
 	if chunkSize < 1 {
 		chunkSize = MIN_CHUNK_SIZE
 	}
 
 	var wg sync.WaitGroup
-	mu := sync.Mutex{}
+	// every worker sends at most one error, so none of them blocks when the channel is only read after all are done
+	errs := make(chan error, numWorkers)
+	semaphore := make(chan struct{}, min(numWorkers, max(maxGoroutines, 1)))
 
-	processChunk := func(chunk []DomainProvider) {
-		localCounts := make(map[string]int)
+	processChunk := func(worker int, chunk []DomainProvider) {
+		defer wg.Done()
+		defer func() { <-semaphore }()
+		defer func() {
+			if r := recover(); r != nil {
+				errs <- fmt.Errorf("%w: %v", ErrProviderPanic, r)
+			}
+		}()
+
+		localCounts := make([]map[string]int, DOMAIN_COUNT_SHARDS)
 		for _, provider := range chunk {
 			domain := provider.GetDomain()
-			localCounts[domain]++
+			shard := maphash.String(seed, domain) % DOMAIN_COUNT_SHARDS
+			if localCounts[shard] == nil {
+				localCounts[shard] = make(map[string]int)
+			}
+			localCounts[shard][domain]++
 		}
 
-		mu.Lock()
-		for domain, count := range localCounts {
-			domainCounts[domain] += count
+		// start from a different shard in every worker, so workers finishing together do not queue for the same lock
+		for i := range shards {
+			shard := (worker + i) % DOMAIN_COUNT_SHARDS
+			if len(localCounts[shard]) == 0 {
+				continue
+			}
+
+			shards[shard].mu.Lock()
+			for domain, count := range localCounts[shard] {
+				shards[shard].counts[domain] += count
+			}
+			shards[shard].mu.Unlock()
 		}
-		mu.Unlock()
-		wg.Done()
 	}
 
+	worker := 0
 	for i := 0; i < totalProviders; i += chunkSize {
 		end := i + chunkSize
 		if end > totalProviders {
 			end = totalProviders
 		}
+		semaphore <- struct{}{}
 		wg.Add(1)
-		go processChunk(providers[i:end])
+		go processChunk(worker, providers[i:end])
+		worker++
 	}
 
 	wg.Wait()
+	close(errs)
 
-	return sortDomainCounts(domainCounts)
-}
-
-// Function "parseCustomerLine" maps single line from CSV file to "customer" struct. It returns an error if data is not valid.
-func parseCustomerLine(csvLine []string, csvLineNumber int) (customer, error) {
-	firstName := csvLine[0]
-	if len(firstName) == 0 {
-		return customer{}, fmt.Errorf("invalid first name at line %d: %s", csvLineNumber, csvLine[0])
+	var panicErrs []error
+	for err := range errs {
+		panicErrs = append(panicErrs, err)
+	}
+	if len(panicErrs) > 0 {
+		return nil, errors.Join(panicErrs...)
 	}
 
-	lastName := csvLine[1]
-	if len(lastName) == 0 {
-		return customer{}, fmt.Errorf("invalid last name at line %d: %s", csvLineNumber, csvLine[1])
+	// shards hold disjoint domains, so they are concatenated without merging
+	totalDomains := 0
+	for i := range shards {
+		totalDomains += len(shards[i].counts)
 	}
 
-	email := email(csvLine[2])
-	if !email.isValid() {
-		return customer{}, fmt.Errorf("invalid email at line %d: %s", csvLineNumber, csvLine[2])
+	domainCountSlice := make([]domainCount, 0, totalDomains)
+	for i := range shards {
+		for domain, count := range shards[i].counts {
+			domainCountSlice = append(domainCountSlice, domainCount{Domain: domain, Count: count})
+		}
 	}
 
-	gender := parseGender(csvLine[3])
+	SortDomainCountsFunc(domainCountSlice, byCountDescending)
 
-	ipAddress := net.ParseIP(csvLine[4])
-	if ipAddress == nil {
-		return customer{}, fmt.Errorf("invalid ip address at line %d: %v", csvLineNumber, csvLine[4])
-	}
+	return domainCountSlice, nil
+}
+
+// Consts "HEADER_*" are column names recognized in CSV header, see "ReadOptions.MapColumnsByHeader".
+const (
+	HEADER_FIRST_NAME  = "first_name"
+	HEADER_MIDDLE_NAME = "middle_name"
+	HEADER_LAST_NAME   = "last_name"
+	HEADER_EMAIL       = "email"
+	HEADER_GENDER      = "gender"
+	HEADER_IP_ADDRESS  = "ip_address"
+)
+
+// Const "NO_COLUMN" marks a customer field without a column in CSV file.
+const NO_COLUMN = -1
 
-	return customer{
-		FirstName: csvLine[0],
-		LastName:  csvLine[1],
-		Email:     email,
-		Gender:    gender,
-		IPAddress: ipAddress,
-	}, nil
+// Type "columnIndex" holds positions of customer fields in a line of CSV file.
+type columnIndex struct {
+	firstName  int
+	middleName int
+	lastName   int
+	email      int
+	gender     int
+	ipAddress  int
+	country    int
 }
 
-// Type "ProcessCSVLineFunc" is used to abstract the processing logic when iterating over lines in a CSV file,
-// allowing for different behaviors while reading and processing the CSV data.
-type ProcessCSVLineFunc func([]string, int) error
+// Variable "defaultColumnIndex" reflects the fixed column order of CSV file: first name, last name, email, gender, ip address.
+var defaultColumnIndex = columnIndex{
+	firstName:  0,
+	middleName: NO_COLUMN,
+	lastName:   1,
+	email:      2,
+	gender:     3,
+	ipAddress:  4,
+	country:    NO_COLUMN,
+}
 
-// Function "ProcessCSVLine" processess a CSV file line by line, saving first line as CSV header.
-// It accepts a callback satisfying "ProcessCSVLineFunc" type as second argument, modyfing behavior for what to do with read lines.
-func ProcessCSVFile(csvReader *csv.Reader, processLine ProcessCSVLineFunc) error {
-	csvLineNumber := CSV_FIRST_LINE_NUMBER
+// Method "minFields" returns the number of fields a line needs to hold all mapped columns.
+func (ci columnIndex) minFields() int {
+	return max(ci.firstName, ci.middleName, ci.lastName, ci.email, ci.gender, ci.ipAddress, ci.country) + 1
+}
 
-	//process first line as header
-	csvHeader, err := csvReader.Read()
-	if err != nil {
-		return err
+// Method "value" returns the field of "csvLine" at given position, or an empty string for a field without a column.
+func (ci columnIndex) value(csvLine []string, position int) string {
+	if position == NO_COLUMN {
+		return ""
 	}
 
-	for {
-		csvLine, err := csvReader.Read()
-		csvLineNumber++
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return fmt.Errorf("error reading CSV at line %d: %w", csvLineNumber, err)
-		}
+	return csvLine[position]
+}
 
-		if isHeaderLine(csvLine, csvHeader) {
-			continue
-		}
+// Method "fieldName" returns the name of customer field read from given position, as reported by "ParseError".
+func (ci columnIndex) fieldName(position int) string {
+	switch position {
+	case ci.firstName:
+		return FIELD_FIRST_NAME
+	case ci.middleName:
+		return FIELD_MIDDLE_NAME
+	case ci.lastName:
+		return FIELD_LAST_NAME
+	case ci.email:
+		return FIELD_EMAIL
+	case ci.gender:
+		return FIELD_GENDER
+	case ci.ipAddress:
+		return FIELD_IP_ADDRESS
+	case ci.country:
+		return FIELD_COUNTRY
+	}
 
-		err = processLine(csvLine, csvLineNumber)
-		if err != nil {
-			return err
+	return fmt.Sprintf("column %d", position+1)
+}
+
+// Method "resolve" fills "resolved" with names of "csvHeader" columns that customer fields are read from, keyed by "FIELD_*" names.
+// Fields without a column are left out.
+func (ci columnIndex) resolve(csvHeader []string, resolved map[string]string) {
+	positions := map[string]int{
+		FIELD_FIRST_NAME:  ci.firstName,
+		FIELD_MIDDLE_NAME: ci.middleName,
+		FIELD_LAST_NAME:   ci.lastName,
+		FIELD_EMAIL:       ci.email,
+		FIELD_GENDER:      ci.gender,
+		FIELD_IP_ADDRESS:  ci.ipAddress,
+		FIELD_COUNTRY:     ci.country,
+	}
+
+	for field, position := range positions {
+		if position != NO_COLUMN && position < len(csvHeader) {
+			resolved[field] = strings.TrimPrefix(csvHeader[position], "\ufeff")
 		}
 	}
+}
 
-	return nil
+// Function "normalizeHeaderName" prepares a CSV header column name for comparison, dropping byte order mark,
+// surrounding spaces and letter case.
+func normalizeHeaderName(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
 }
 
-// Function "ReadCustomersFromCSV" reads data from CSV file into a slice of "customer" type.
-// It stores data in memory and should be avoided for larger datasets.
-func ReadCustomersFromCSV(r io.Reader) ([]customer, error) {
-	reader := csv.NewReader(r)
+// Function "HeadersCompatible" checks whether two CSV headers have the same columns in the same order, so files can be
+// merged as they are. Column names are compared like in "ReadOptions.MapColumnsByHeader", ignoring case and surrounding spaces.
+func HeadersCompatible(a, b []string) bool {
+	return slices.EqualFunc(a, b, func(x, y string) bool {
+		return normalizeHeaderName(x) == normalizeHeaderName(y)
+	})
+}
 
-	var customers []customer
+// Function "HeadersCompatibleAnyOrder" works like "HeadersCompatible", allowing columns in any order.
+// Such files can be merged when read with "ReadOptions.MapColumnsByHeader". Repeated columns must be repeated equally often.
+func HeadersCompatibleAnyOrder(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
 
-	err := ProcessCSVFile(reader, func(csvLine []string, csvLineNumber int) error {
-		customer, err := parseCustomerLine(csvLine, csvLineNumber)
+	columns := make(map[string]int, len(a))
+	for _, name := range a {
+		columns[normalizeHeaderName(name)]++
+	}
+
+	for _, name := range b {
+		name = normalizeHeaderName(name)
+		if columns[name] == 0 {
+			return false
+		}
+		columns[name]--
+	}
+
+	return true
+}
+
+// Function "mapColumnsByHeader" finds positions of customer fields by column names in CSV header.
+// Names are matched case-insensitively, unknown columns are ignored. Middle name and gender columns are optional.
+// Of columns with the same name the first one is read, like with "ReadOptions.Schema".
+// A country column is only read through "ReadOptions.Schema", so files with free-form country names still import.
+func mapColumnsByHeader(csvHeader []string) (columnIndex, error) {
+	columns := columnIndex{
+		firstName:  NO_COLUMN,
+		middleName: NO_COLUMN,
+		lastName:   NO_COLUMN,
+		email:      NO_COLUMN,
+		gender:     NO_COLUMN,
+		ipAddress:  NO_COLUMN,
+		country:    NO_COLUMN,
+	}
+
+	for i, name := range csvHeader {
+		name = normalizeHeaderName(name)
+
+		var position *int
+		switch name {
+		case HEADER_FIRST_NAME:
+			position = &columns.firstName
+		case HEADER_MIDDLE_NAME:
+			position = &columns.middleName
+		case HEADER_LAST_NAME:
+			position = &columns.lastName
+		case HEADER_EMAIL:
+			position = &columns.email
+		case HEADER_GENDER:
+			position = &columns.gender
+		case HEADER_IP_ADDRESS:
+			position = &columns.ipAddress
+		}
+
+		if position != nil && *position == NO_COLUMN {
+			*position = i
+		}
+	}
+
+	required := []struct {
+		name     string
+		position int
+	}{
+		{HEADER_FIRST_NAME, columns.firstName},
+		{HEADER_LAST_NAME, columns.lastName},
+		{HEADER_EMAIL, columns.email},
+		{HEADER_IP_ADDRESS, columns.ipAddress},
+	}
+
+	for _, column := range required {
+		if column.position == NO_COLUMN {
+			return columns, fmt.Errorf("missing column %s in CSV header", column.name)
+		}
+	}
+
+	return columns, nil
+}
+
+// Consts "FIELD_*" name customer fields reported by "ParseError".
+// "FIELD_CUSTOMER" is reported when the customer as a whole is rejected by a "CustomerValidatorFunc".
+const (
+	FIELD_FIRST_NAME  = "first name"
+	FIELD_MIDDLE_NAME = "middle name"
+	FIELD_LAST_NAME   = "last name"
+	FIELD_EMAIL       = "email"
+	FIELD_GENDER      = "gender"
+	FIELD_IP_ADDRESS  = "ip address"
+	FIELD_COUNTRY     = "country"
+	FIELD_CUSTOMER    = "customer"
+)
+
+// Type "ParseError" describes a CSV line that could not be mapped to "customer" struct because of an invalid field.
+// "Err" holds the underlying reason when there is one, e.g. an error returned by a "CustomerValidatorFunc".
+// "Record" holds all fields of the rejected line as read from the file, before any normalization or "ReadOptions.Transform",
+// it is filled in by "ProcessCSVFile".
+type ParseError struct {
+	Line   int
+	Field  string
+	Value  string
+	Err    error
+	Record []string
+}
+
+func (e *ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid %s at line %d: %v", e.Field, e.Line, e.Err)
+	}
+	return fmt.Sprintf("invalid %s at line %d: %s", e.Field, e.Line, e.Value)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Variable "ErrHostnameNotIP" is wrapped by "ParseError" when the IP address column holds a hostname, hinting at swapped columns.
+var ErrHostnameNotIP = errors.New("expected IP address, got hostname-like value")
+
+// Variable "ErrLowValidRatio" is returned when too few lines are valid to trust the import, see "ReadOptions.MinValidRatio".
+var ErrLowValidRatio = errors.New("too many invalid lines, check the input file")
+
+// Variable "ErrInvalidUTF8" is wrapped by "ParseError" when a field is not valid UTF-8, see "ReadOptions.ValidateUTF8".
+var ErrInvalidUTF8 = errors.New("invalid UTF-8 encoding")
+
+// Variable "ErrMissingFields" is wrapped by "ParseError" when a line has fewer fields than needed for mapped columns.
+var ErrMissingFields = errors.New("missing fields")
+
+// Variable "ErrNoEmailColumn" is returned when domains are counted with a "ReadOptions.Schema" that maps no column to email.
+var ErrNoEmailColumn = errors.New("schema maps no column to email")
+
+// Function "isStructuralError" checks whether an error means the file itself is malformed, e.g. a line with a wrong number
+// of fields or a failed read, rather than a single line holding invalid customer data. Only errors of the latter kind,
+// "ParseError" not wrapping "ErrMissingFields", are skipped with "ReadOptions.SkipInvalid".
+func isStructuralError(err error) bool {
+	var parseErr *ParseError
+	return !errors.As(err, &parseErr) || errors.Is(err, ErrMissingFields) || errors.Is(err, csv.ErrFieldCount)
+}
+
+// Variable "ErrProviderPanic" is wrapped by the error "CountDomainsConcurrent" returns when a "DomainProvider" panics.
+var ErrProviderPanic = errors.New("domain provider panicked")
+
+// Function "looksLikeHostname" checks whether an invalid IP address value resembles a hostname, having letters but
+// no colons, which excludes IPv6 addresses written with hex digits.
+func looksLikeHostname(value string) bool {
+	if strings.Contains(value, ":") {
+		return false
+	}
+
+	return strings.ContainsFunc(value, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	})
+}
+
+// Type "CustomerValidatorFunc" is used to apply additional validation rules to a parsed customer,
+// see "ReadOptions.Validators". Returning an error rejects the customer.
+type CustomerValidatorFunc func(*customer) error
+
+// Function "validateCustomerFields" checks fields of a CSV line mapped to customer, except IP address and custom validators.
+// With "ReadOptions.SanitizeUTF8" or "ReadOptions.AllowDisplayName" set, "csvLine" fields are normalized in place.
+// It is shared by full parsing and "ReadOptions.CountOnly" path, so both reject the same lines the same way.
+func validateCustomerFields(csvLine []string, csvLineNumber int, opts ReadOptions, columns columnIndex) error {
+	if len(csvLine) < columns.minFields() {
+		err := fmt.Errorf("%w: expected %d fields, got %d", ErrMissingFields, columns.minFields(), len(csvLine))
+		return &ParseError{Line: csvLineNumber, Field: FIELD_CUSTOMER, Err: err}
+	}
+
+	if opts.ValidateUTF8 || opts.SanitizeUTF8 {
+		for i, field := range csvLine {
+			if utf8.ValidString(field) {
+				continue
+			}
+			if !opts.SanitizeUTF8 {
+				return &ParseError{Line: csvLineNumber, Field: columns.fieldName(i), Err: ErrInvalidUTF8}
+			}
+			csvLine[i] = strings.ToValidUTF8(field, string(utf8.RuneError))
+		}
+	}
+
+	if opts.MaxFieldLen > 0 {
+		for i, field := range csvLine {
+			if len(field) > opts.MaxFieldLen {
+				err := fmt.Errorf("field exceeds maximum length of %d bytes", opts.MaxFieldLen)
+				return &ParseError{Line: csvLineNumber, Field: columns.fieldName(i), Err: err}
+			}
+		}
+	}
+
+	if opts.AllowDisplayName && columns.email != NO_COLUMN {
+		address, err := mail.ParseAddress(csvLine[columns.email])
+		if err == nil {
+			csvLine[columns.email] = address.Address
+		}
+	}
+
+	// fields without a column are only possible with "ReadOptions.Schema" and are not validated
+	if columns.firstName != NO_COLUMN {
+		firstName := csvLine[columns.firstName]
+		if len(firstName) == 0 {
+			return &ParseError{Line: csvLineNumber, Field: FIELD_FIRST_NAME, Value: firstName}
+		}
+	}
+
+	if columns.lastName != NO_COLUMN {
+		lastName := csvLine[columns.lastName]
+		if len(lastName) == 0 {
+			return &ParseError{Line: csvLineNumber, Field: FIELD_LAST_NAME, Value: lastName}
+		}
+	}
+
+	if columns.email != NO_COLUMN {
+		err := validateEmailField(email(csvLine[columns.email]), csvLineNumber, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if columns.country != NO_COLUMN {
+		country := normalizeCountryCode(csvLine[columns.country])
+		if country != "" && !isCountryCodeFormat(country) {
+			err := errors.New("country must be an ISO 3166-1 alpha-2 code")
+			return &ParseError{Line: csvLineNumber, Field: FIELD_COUNTRY, Value: csvLine[columns.country], Err: err}
+		}
+
+		if country != "" && opts.StrictCountry && !isKnownCountryCode(country) {
+			err := errors.New("unknown ISO 3166-1 alpha-2 country code")
+			return &ParseError{Line: csvLineNumber, Field: FIELD_COUNTRY, Value: csvLine[columns.country], Err: err}
+		}
+	}
+
+	return nil
+}
+
+// Function "validateEmailField" checks an email according to "ReadOptions", returning a "ParseError" when it is invalid.
+func validateEmailField(email email, csvLineNumber int, opts ReadOptions) error {
+	if !email.isValidWithOptions(opts) && !(opts.LenientDomain && email.hasDomain()) {
+		return &ParseError{Line: csvLineNumber, Field: FIELD_EMAIL, Value: string(email)}
+	}
+
+	if opts.StrictDomain && !email.hasStrictDomain() {
+		err := errors.New("domain must have at least two labels and an alphabetic TLD")
+		return &ParseError{Line: csvLineNumber, Field: FIELD_EMAIL, Value: string(email), Err: err}
+	}
+
+	return nil
+}
+
+// Function "hasInvalidEmail" checks whether a line rejected for any field also has an invalid email, for "ReadStats.InvalidEmails".
+func hasInvalidEmail(csvLine []string, opts ReadOptions, columns columnIndex) bool {
+	if columns.email == NO_COLUMN || columns.email >= len(csvLine) {
+		return false
+	}
+
+	value := csvLine[columns.email]
+	if opts.AllowDisplayName {
+		// the line may be rejected before the display name was stripped
+		address, err := mail.ParseAddress(value)
+		if err == nil {
+			value = address.Address
+		}
+	}
+
+	return validateEmailField(email(value), 0, opts) != nil
+}
+
+// Function "invalidIPAddressError" describes an IP address that could not be parsed, hinting when it resembles a hostname.
+func invalidIPAddressError(value string, csvLineNumber int) error {
+	if looksLikeHostname(value) {
+		err := fmt.Errorf("%w: %s", ErrHostnameNotIP, value)
+		return &ParseError{Line: csvLineNumber, Field: FIELD_IP_ADDRESS, Value: value, Err: err}
+	}
+
+	return &ParseError{Line: csvLineNumber, Field: FIELD_IP_ADDRESS, Value: value}
+}
+
+// Function "parseEmailLine" validates a CSV line exactly like "parseCustomerLineWithOptions" and returns just customer's email.
+// It avoids building "customer" struct and checks IP address with allocation-free "netip.ParseAddr".
+func parseEmailLine(csvLine []string, csvLineNumber int, opts ReadOptions, columns columnIndex) (email, error) {
+	err := validateCustomerFields(csvLine, csvLineNumber, opts, columns)
+	if err != nil {
+		return "", err
+	}
+
+	if columns.ipAddress != NO_COLUMN {
+		// "net.ParseIP" does not accept IPv6 zones, so neither does this path
+		ipAddress, err := netip.ParseAddr(csvLine[columns.ipAddress])
+		if err != nil || ipAddress.Zone() != "" {
+			return "", invalidIPAddressError(csvLine[columns.ipAddress], csvLineNumber)
+		}
+	}
+
+	return email(columns.value(csvLine, columns.email)), nil
+}
+
+// Function "parseCustomerLine" maps single line from CSV file to "customer" struct. It returns a "ParseError" if data is not valid.
+func parseCustomerLine(csvLine []string, csvLineNumber int) (customer, error) {
+	return parseCustomerLineWithOptions(csvLine, csvLineNumber, ReadOptions{}, defaultColumnIndex)
+}
+
+// Function "parseCustomerLineWithOptions" works like "parseCustomerLine", validating data according to "ReadOptions"
+// and reading fields from positions given by "columns".
+func parseCustomerLineWithOptions(csvLine []string, csvLineNumber int, opts ReadOptions, columns columnIndex) (customer, error) {
+	err := validateCustomerFields(csvLine, csvLineNumber, opts, columns)
+	if err != nil {
+		return customer{}, err
+	}
+
+	firstName := columns.value(csvLine, columns.firstName)
+	middleName := columns.value(csvLine, columns.middleName)
+	lastName := columns.value(csvLine, columns.lastName)
+	email := email(columns.value(csvLine, columns.email))
+	country := normalizeCountryCode(columns.value(csvLine, columns.country))
+
+	gender := unknown
+	if columns.gender != NO_COLUMN {
+		gender = parseGender(csvLine[columns.gender])
+	}
+
+	var ipAddress net.IP
+	if columns.ipAddress != NO_COLUMN {
+		ipAddress = net.ParseIP(csvLine[columns.ipAddress])
+		if ipAddress == nil {
+			return customer{}, invalidIPAddressError(csvLine[columns.ipAddress], csvLineNumber)
+		}
+		// store IPv4 addresses in their 4-byte form, so they compare equal regardless of how they were written
+		if ipv4 := ipAddress.To4(); ipv4 != nil {
+			ipAddress = ipv4
+		}
+	}
+
+	parsed := customer{
+		FirstName:  firstName,
+		MiddleName: middleName,
+		LastName:   lastName,
+		Email:      email,
+		Gender:     gender,
+		IPAddress:  ipAddress,
+		Country:    country,
+	}
+
+	for _, validate := range opts.Validators {
+		err := validate(&parsed)
+		if err != nil {
+			return customer{}, &ParseError{Line: csvLineNumber, Field: FIELD_CUSTOMER, Err: err}
+		}
+	}
+
+	return parsed, nil
+}
+
+// Type "ProcessCSVLineFunc" is used to abstract the processing logic when iterating over lines in a CSV file,
+// allowing for different behaviors while reading and processing the CSV data.
+// The line slice may be reused by the reader (see "csv.Reader.ReuseRecord") and must not be retained after returning,
+// while the strings it holds are safe to keep.
+type ProcessCSVLineFunc func([]string, int) error
+
+// Function "ProcessCSVLine" processess a CSV file line by line, saving first line as CSV header.
+// It accepts a callback satisfying "ProcessCSVLineFunc" type as second argument, modyfing behavior for what to do with read lines.
+// An empty file is not an error, the callback is simply never called.
+func ProcessCSVFile(csvReader *csv.Reader, processLine ProcessCSVLineFunc) error {
+	return processCSVFile(csvReader, ReadOptions{}, nil, processLine)
+}
+
+// Function "processCSVFile" works like "ProcessCSVFile", handling the structure of CSV file according to "ReadOptions".
+// Errors of reading, e.g. "csv.ErrFieldCount" or those of the underlying reader, always stop processing, regardless of
+// "ReadOptions.SkipInvalid", which only applies to errors of "processLine".
+// If "processHeader" is not nil, it is called with CSV header before any line is processed.
+func processCSVFile(csvReader recordReader, opts ReadOptions, processHeader func([]string) error, processLine ProcessCSVLineFunc) error {
+	csvLineNumber := CSV_FIRST_LINE_NUMBER
+
+	//process first line as header, empty file has no lines to process
+	csvHeader, err := csvReader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	// copy, as the reader may reuse the slice for subsequent lines
+	csvHeader = append([]string(nil), csvHeader...)
+
+	if processHeader != nil {
+		err = processHeader(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		csvLine, err := csvReader.Read()
+		csvLineNumber++
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			if opts.IgnoreFooter && errors.Is(err, csv.ErrFieldCount) && isLastLine(csvReader) {
+				break
+			}
+			return fmt.Errorf("error reading CSV at line %d: %w", csvLineNumber, err)
+		}
+
+		if isHeaderLine(csvLine, csvHeader) {
+			continue
+		}
+
+		err = processLine(csvLine, csvLineNumber)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) && parseErr.Record == nil {
+				// copy, as the reader may reuse the slice for subsequent lines
+				parseErr.Record = append([]string(nil), csvLine...)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Function "isLastLine" checks whether nothing is left to read after the current line.
+func isLastLine(csvReader recordReader) bool {
+	_, err := csvReader.Read()
+	return err == io.EOF
+}
+
+// Function "newAutoReader" detects gzip compressed input by its leading bytes and decompresses it transparently.
+// Closing the returned reader releases the decompressor, reporting its errors, but does not close "r".
+// Uncompressed input is returned as is.
+func newAutoReader(r io.Reader) (io.ReadCloser, error) {
+	bufferedReader := bufio.NewReader(r)
+
+	magic, err := bufferedReader.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, gzipMagic) {
+		return gzip.NewReader(bufferedReader)
+	}
+
+	return io.NopCloser(bufferedReader), nil
+}
+
+// Function "prevalidateFirstRow" wraps "processLine" so that the first data line is checked against the expected schema
+// before any further processing, failing fast with a descriptive error when the file layout is obviously wrong.
+// Columns are passed by pointer, as they are known only after CSV header is read.
+func prevalidateFirstRow(processLine ProcessCSVLineFunc, opts ReadOptions, columns *columnIndex) ProcessCSVLineFunc {
+	validated := false
+
+	return func(csvLine []string, csvLineNumber int) error {
+		if !validated {
+			validated = true
+
+			if len(csvLine) < columns.minFields() {
+				return fmt.Errorf("first data row failed prevalidation at line %d: expected %d fields, got %d", csvLineNumber, columns.minFields(), len(csvLine))
+			}
+
+			_, err := parseCustomerLineWithOptions(csvLine, csvLineNumber, opts, *columns)
+			if err != nil {
+				return fmt.Errorf("first data row failed prevalidation, check the file schema: %w", err)
+			}
+		}
+
+		return processLine(csvLine, csvLineNumber)
+	}
+}
+
+// Function "processCustomersFromCSV" reads CSV file according to "ReadOptions" and calls "processCustomer" for every parsed customer.
+func processCustomersFromCSV(r io.Reader, opts ReadOptions, processCustomer func(customer) error) error {
+	return processParsedLines(r, opts, false, parseCustomerLineWithOptions, processCustomer)
+}
+
+// Function "processParsedLines" reads CSV file according to "ReadOptions", maps every line with "parseLine" and calls
+// "process" with the result. It takes care of header mapping, first row prevalidation, skipping invalid lines and statistics.
+// With "requireEmail" set, e.g. when counting domains, a header mapped without an email column fails with "ErrNoEmailColumn".
+func processParsedLines[T any](r io.Reader, opts ReadOptions, requireEmail bool, parseLine func([]string, int, ReadOptions, columnIndex) (T, error), process func(T) error) error {
+	stats := opts.Stats
+	if stats == nil {
+		stats = &ReadStats{}
+	} else {
+		// bytes are only counted when asked for, sparing the indirection otherwise
+		r = &countingReader{r: r, count: &stats.Bytes}
+	}
+	*stats = ReadStats{}
+
+	reader, err := newRecordReader(r, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.ResolvedColumns != nil {
+		clear(opts.ResolvedColumns)
+	}
+
+	columns := defaultColumnIndex
+	processHeader := func(csvHeader []string) error {
+		var err error
+		switch {
+		case opts.Schema != nil:
+			columns, err = opts.Schema.mapColumns(csvHeader)
+		case opts.MapColumnsByHeader:
+			columns, err = mapColumnsByHeader(csvHeader)
+		}
 		if err != nil {
 			return err
 		}
+		if requireEmail && columns.email == NO_COLUMN {
+			return ErrNoEmailColumn
+		}
+
+		if opts.ResolvedColumns != nil {
+			columns.resolve(csvHeader, opts.ResolvedColumns)
+		}
+		return nil
+	}
+
+	processLine := func(csvLine []string, csvLineNumber int) error {
+		stats.Lines++
+
+		value, err := parseLine(csvLine, csvLineNumber, opts, columns)
+		if err != nil {
+			if !opts.SkipInvalid || isStructuralError(err) {
+				return err
+			}
+
+			var parseErr *ParseError
+			errors.As(err, &parseErr)
+			stats.Skipped++
+			if parseErr.Field == FIELD_EMAIL || hasInvalidEmail(csvLine, opts, columns) {
+				stats.InvalidEmails++
+			}
+			return nil
+		}
+
+		return process(value)
+	}
+
+	if opts.PrevalidateFirstRow {
+		processLine = prevalidateFirstRow(processLine, opts, &columns)
+	}
+
+	if opts.Transform != nil {
+		validateLine := processLine
+		processLine = func(csvLine []string, csvLineNumber int) error {
+			return validateLine(opts.Transform(csvLine), csvLineNumber)
+		}
+	}
+
+	if opts.TrimFieldSpace {
+		trimmedLine := processLine
+		processLine = func(csvLine []string, csvLineNumber int) error {
+			for i, field := range csvLine {
+				csvLine[i] = strings.TrimSpace(field)
+			}
+			return trimmedLine(csvLine, csvLineNumber)
+		}
+	}
+
+	if opts.TrimFieldSpace || opts.Transform != nil || opts.SanitizeUTF8 || opts.AllowDisplayName {
+		// the line is normalized in place, so it is kept as read for "ParseError.Record"
+		var originalLine []string
+		normalizedLine := processLine
+		processLine = func(csvLine []string, csvLineNumber int) error {
+			originalLine = append(originalLine[:0], csvLine...)
+			err := normalizedLine(csvLine, csvLineNumber)
+
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) && parseErr.Record == nil {
+				parseErr.Record = append([]string(nil), originalLine...)
+			}
+			return err
+		}
+	}
+
+	err = processCSVFile(reader, opts, processHeader, processLine)
+	if err != nil {
+		return err
+	}
+
+	if opts.SkipInvalid && opts.MinValidRatio > 0 && stats.Lines > 0 {
+		validRatio := float64(stats.Lines-stats.Skipped) / float64(stats.Lines)
+		if validRatio < opts.MinValidRatio {
+			return fmt.Errorf("%w: %d of %d lines valid (%.1f%%), want at least %.1f%%", ErrLowValidRatio,
+				stats.Lines-stats.Skipped, stats.Lines, 100*validRatio, 100*opts.MinValidRatio)
+		}
+	}
+
+	return nil
+}
+
+// Function "ReadHeader" reads and returns the columns of CSV header, configuring the reader according to "ReadOptions".
+// It consumes the input, and due to buffering possibly more than the header line, so "r" should not be read further.
+// Empty input results in an empty header.
+func ReadHeader(r io.Reader, opts ReadOptions) ([]string, error) {
+	reader, err := newCSVReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	csvHeader, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
 
+	return csvHeader, nil
+}
+
+// Function "ReadCustomersFromCSV" reads data from CSV file into a slice of "customer" type.
+// It stores data in memory and should be avoided for larger datasets.
+// A file without data lines (empty or header only) results in an empty, non-nil slice, while a nil slice is
+// only returned together with an error. The same applies to all functions returning customers or domain counts.
+func ReadCustomersFromCSV(r io.Reader) ([]customer, error) {
+	return ReadCustomersFromCSVWithOptions(r, ReadOptions{})
+}
+
+// Function "ReadCustomersFromCSVWithOptions" works like "ReadCustomersFromCSV", reading the CSV file according to "ReadOptions".
+func ReadCustomersFromCSVWithOptions(r io.Reader, opts ReadOptions) ([]customer, error) {
+	customers, err := appendCustomersFromCSV([]customer{}, r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return customers, nil
+}
+
+// Function "AppendCustomersFromCSV" works like "ReadCustomersFromCSV", appending customers to "dst" and returning the extended slice,
+// so a buffer can be reused across imports. On error "dst" is returned with its original length, without partially read customers.
+func AppendCustomersFromCSV(dst []customer, r io.Reader) ([]customer, error) {
+	return appendCustomersFromCSV(dst, r, ReadOptions{})
+}
+
+// Function "appendCustomersFromCSV" reads CSV file according to "ReadOptions", appending customers to "dst".
+func appendCustomersFromCSV(dst []customer, r io.Reader, opts ReadOptions) ([]customer, error) {
+	customers := dst
+	if customers == nil {
+		customers = []customer{}
+	}
+	if opts.EstimatedRows > 0 {
+		customers = slices.Grow(customers, opts.EstimatedRows)
+	}
+
+	err := processCustomersFromCSV(r, opts, func(customer customer) error {
 		customers = append(customers, customer)
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return dst, err
 	}
 
 	return customers, nil
 }
 
+// Variable "errStopIteration" is returned internally to stop reading when an iterator consumer breaks out of the loop.
+var errStopIteration = errors.New("iteration stopped")
+
+// Function "CustomersSeq" returns an iterator over customers read from CSV file, to be used with "for c, err := range".
+// Customers are parsed one by one as the loop advances, without holding them in memory. Iteration stops after the first error.
+func CustomersSeq(r io.Reader) iter.Seq2[customer, error] {
+	return customersSeq(r, ReadOptions{})
+}
+
+// Function "customersSeq" works like "CustomersSeq", reading the CSV file according to "ReadOptions".
+func customersSeq(r io.Reader, opts ReadOptions) iter.Seq2[customer, error] {
+	return func(yield func(customer, error) bool) {
+		err := processCustomersFromCSV(r, opts, func(customer customer) error {
+			if !yield(customer, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && err != errStopIteration {
+			yield(customer{}, err)
+		}
+	}
+}
+
+// Type "CustomerReader" reads customers from CSV file in batches of caller-chosen size, e.g. to apply backpressure
+// while processing a very large file. Lines are only read as batches are requested. It is not safe for concurrent use.
+type CustomerReader struct {
+	next func() (customer, error, bool)
+	stop func()
+	err  error
+}
+
+// Function "NewCustomerReader" returns a "CustomerReader" reading CSV file from "r" according to "ReadOptions".
+// "Close" should be called when the reader is not read until the end, to release resources used while reading.
+func NewCustomerReader(r io.Reader, opts ReadOptions) *CustomerReader {
+	next, stop := iter.Pull2(customersSeq(r, opts))
+	return &CustomerReader{next: next, stop: stop}
+}
+
+// Method "ReadBatch" returns up to "n" next customers, fewer only when the file ends or an error occurs. Once all customers
+// are returned, it returns "io.EOF". On error, customers read before it are returned with the error, which is then
+// returned by every later call.
+func (cr *CustomerReader) ReadBatch(n int) ([]customer, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid batch size %d", n)
+	}
+	if cr.err != nil {
+		return nil, cr.err
+	}
+
+	batch := make([]customer, 0, n)
+	for len(batch) < n {
+		customer, err, ok := cr.next()
+		if !ok {
+			cr.err = io.EOF
+			break
+		}
+		if err != nil {
+			cr.err = err
+			return batch, err
+		}
+		batch = append(batch, customer)
+	}
+
+	if len(batch) == 0 {
+		return nil, cr.err
+	}
+
+	return batch, nil
+}
+
+// Method "Close" stops reading, after which "ReadBatch" returns "io.EOF". It does not close the underlying reader.
+func (cr *CustomerReader) Close() error {
+	cr.stop()
+	if cr.err == nil {
+		cr.err = io.EOF
+	}
+
+	return nil
+}
+
 // Function "ReadAndCountDomainsFromCSV" reads data from CSV file and processes it to return a count of each unique domain,
 // sorted by their occurences. It does it by processing lines one by one and discarding them afterwards.
 func ReadAndCountDomainsFromCSV(r io.Reader) ([]domainCount, error) {
-	reader := csv.NewReader(r)
+	return ReadAndCountDomainsFromCSVWithOptions(r, ReadOptions{})
+}
 
-	domainCounts := make(map[string]int)
+// Function "ReadAndCountDomainsFromCSVWithOptions" works like "ReadAndCountDomainsFromCSV", reading the CSV file according to "ReadOptions".
+func ReadAndCountDomainsFromCSVWithOptions(r io.Reader, opts ReadOptions) ([]domainCount, error) {
+	domainCounter := newDomainCounterWithSize(opts.EstimatedRows)
 
-	err := ProcessCSVFile(reader, func(csvLine []string, csvLineNumber int) error {
+	err := countDomainsFromCSV(r, opts, domainCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
+
+// Function "ReadAndCountDomainsFromReaderWithClose" counts domains like "ReadAndCountDomainsFromCSV" and closes "rc" when done,
+// e.g. the body of an S3 GetObject response or HTTP response. "rc" is closed even if reading fails, errors of both are returned.
+func ReadAndCountDomainsFromReaderWithClose(rc io.ReadCloser) ([]domainCount, error) {
+	counts, err := ReadAndCountDomainsFromCSV(rc)
+
+	closeErr := rc.Close()
+	if err != nil || closeErr != nil {
+		return nil, errors.Join(err, closeErr)
+	}
+
+	return counts, nil
+}
+
+// Type "domainCountWithOriginal" extends "domainCount" of a normalized domain with its "Original" spelling, e.g. "Example.COM"
+// for "example.com", to be shown to users.
+type domainCountWithOriginal struct {
+	domainCount
+	Original string `json:"original"`
+}
+
+// Function "ReadAndCountDomainsWithOriginal" counts domains like "ReadAndCountDomainsFromCSVWithOptions", also keeping
+// the original spelling of every domain as written in the first email it was counted from. Counts are sorted the same way.
+func ReadAndCountDomainsWithOriginal(r io.Reader, opts ReadOptions) ([]domainCountWithOriginal, error) {
+	domainCounter := newDomainCounterWithSize(opts.EstimatedRows)
+	originals := map[string]string{}
+
+	err := countEmailsFromCSV(r, opts, func(e email, domain string) {
+		domainCounter.Add(domain)
+		if _, exists := originals[domain]; !exists {
+			_, original, _ := strings.Cut(string(e), "@")
+			originals[domain] = original
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := domainCounter.Counts()
+	withOriginals := make([]domainCountWithOriginal, len(counts))
+	for i, dc := range counts {
+		withOriginals[i] = domainCountWithOriginal{domainCount: dc, Original: originals[dc.Domain]}
+	}
+
+	return withOriginals, nil
+}
+
+// Function "ReadAndCountDomainsFromTSV" works like "ReadAndCountDomainsFromCSV" for tab-separated files.
+func ReadAndCountDomainsFromTSV(r io.Reader) ([]domainCount, error) {
+	return ReadAndCountDomainsFromCSVWithOptions(r, ReadOptions{Delimiter: '\t'})
+}
+
+// Function "countDomainsFromCSV" reads CSV file according to "ReadOptions", adding domains of customers to "domainCounter".
+// With "ReadOptions.CountOnly" set and no validators, lines are checked without building "customer" structs.
+// Role accounts excluded by "ReadOptions.ExcludeRoleAccounts" and domains rejected by "ReadOptions.DomainFilter" are not added.
+func countDomainsFromCSV(r io.Reader, opts ReadOptions, domainCounter *DomainCounter) error {
+	return countEmailsFromCSV(r, opts, func(_ email, domain string) {
+		domainCounter.Add(domain)
+	})
+}
+
+// Function "countEmailsFromCSV" works like "countDomainsFromCSV", calling "add" with every counted email and its normalized domain.
+func countEmailsFromCSV(r io.Reader, opts ReadOptions, add func(e email, domain string)) error {
+	roleAccounts := opts.RoleAccounts
+	if roleAccounts == nil {
+		roleAccounts = DefaultRoleAccounts
+	}
+
+	addEmail := func(e email) error {
+		if opts.ExcludeRoleAccounts && e.isRoleAccount(roleAccounts) {
+			return nil
+		}
+
+		domain := e.extractDomainWithOptions(opts)
+		if opts.DomainFilter == nil || opts.DomainFilter(domain) {
+			add(e, domain)
+		}
+		return nil
+	}
+
+	if opts.CountOnly && len(opts.Validators) == 0 {
+		return processParsedLines(r, opts, true, parseEmailLine, addEmail)
+	}
+
+	return processParsedLines(r, opts, true, parseCustomerLineWithOptions, func(customer customer) error {
+		return addEmail(customer.Email)
+	})
+}
+
+// Function "AddCountsFromCSV" merges domain counts read from CSV file into previously computed counts, returning
+// the merged slice sorted like "ReadAndCountDomainsFromCSV". It allows incremental updates without recounting
+// older files. The existing slice is not modified.
+func AddCountsFromCSV(existing []domainCount, r io.Reader) ([]domainCount, error) {
+	domainCounter := NewDomainCounter()
+	for _, dc := range existing {
+		domainCounter.AddCount(dc.Domain, dc.Count)
+	}
+
+	err := countDomainsFromCSV(r, ReadOptions{}, domainCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
+
+// Function "CountDomainsFromCSV" counts domains of emails found in given column (counted from 0) of CSV file.
+// Only the email column is read and validated, other columns are ignored and lines may have varying number of fields.
+func CountDomainsFromCSV(r io.Reader, emailColumn int) ([]domainCount, error) {
+	if emailColumn < 0 {
+		return nil, fmt.Errorf("invalid email column %d", emailColumn)
+	}
+
+	reader, err := newCSVReader(r, ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	reader.FieldsPerRecord = -1
+
+	domainCounter := NewDomainCounter()
+
+	err = ProcessCSVFile(reader, func(csvLine []string, csvLineNumber int) error {
+		if emailColumn >= len(csvLine) {
+			err := fmt.Errorf("expected at least %d fields, got %d", emailColumn+1, len(csvLine))
+			return &ParseError{Line: csvLineNumber, Field: FIELD_EMAIL, Err: err}
+		}
+
+		email := email(csvLine[emailColumn])
+		if !email.isValid() {
+			return &ParseError{Line: csvLineNumber, Field: FIELD_EMAIL, Value: string(email)}
+		}
+
+		domainCounter.Add(email.extractDomain())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
+
+// Function "CountDomainsFromCSVReader" counts domains like "ReadAndCountDomainsFromCSV", reading from an already configured
+// CSV reader. It lets callers set up the reader themselves, e.g. with custom "Comma" or "LazyQuotes".
+func CountDomainsFromCSVReader(r *csv.Reader) ([]domainCount, error) {
+	domainCounter := NewDomainCounter()
+
+	err := ProcessCSVFile(r, func(csvLine []string, csvLineNumber int) error {
 		customer, err := parseCustomerLine(csvLine, csvLineNumber)
 		if err != nil {
 			return err
 		}
 
-		domain := email.extractDomain(customer.Email)
-		domainCounts[domain]++
+		domainCounter.Add(customer.GetDomain())
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	sortedDomainCounts := sortDomainCounts(domainCounts)
+	return domainCounter.Counts(), nil
+}
+
+// Function "ReadAndCountDomainsFromFile" opens CSV file at given path and counts domains like "ReadAndCountDomainsFromCSV".
+// Gzip compressed files are detected and decompressed automatically. The file is closed before returning.
+func ReadAndCountDomainsFromFile(path string) ([]domainCount, error) {
+	domainCounter := NewDomainCounter()
+
+	err := countDomainsFromFile(path, domainCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
+
+// Function "countDomainsFromFile" opens CSV file at given path, possibly gzip compressed, adding domains of customers to "domainCounter".
+func countDomainsFromFile(path string, domainCounter *DomainCounter) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := newAutoReader(file)
+	if err != nil {
+		return err
+	}
+
+	err = countDomainsFromCSV(r, ReadOptions{}, domainCounter)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Function "CountDomainsFromDir" counts domains across all files in "dir" with names matching "glob" pattern, e.g. "*.csv",
+// reading them like "ReadAndCountDomainsFromFile". Subdirectories are ignored. An error names the file it occurred in.
+func CountDomainsFromDir(dir string, glob string) ([]domainCount, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, glob))
+	if err != nil {
+		return nil, err
+	}
+
+	domainCounter := NewDomainCounter()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		err = countDomainsFromFile(path, domainCounter)
+		if err != nil {
+			return nil, fmt.Errorf("error counting domains in file %s: %w", path, err)
+		}
+	}
 
-	return sortedDomainCounts, nil
+	return domainCounter.Counts(), nil
 }
@@ -1,11 +1,24 @@
 package customerimporter
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 // Benchmark for the synchronous CountDomains function
@@ -52,7 +65,7 @@ func BenchmarkCountDomainsConcurrent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		CountDomainsConcurrent(providers)
+		_, _ = CountDomainsConcurrent(providers)
 	}
 }
 
@@ -72,6 +85,55 @@ func BenchmarkReadAndCountDomainsFromCSV(b *testing.B) {
 	}
 }
 
+// Benchmark for ReadAndCountDomainsFromCSVWithOptions with and without the CountOnly fast path
+func BenchmarkReadAndCountDomainsFromCSVCountOnly(b *testing.B) {
+	for _, countOnly := range []bool{false, true} {
+		b.Run(fmt.Sprintf("CountOnly=%v", countOnly), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				file, err := os.Open("../customers_1mil.csv")
+				if err != nil {
+					b.Fatalf("failed to open file: %v", err)
+				}
+
+				_, err = ReadAndCountDomainsFromCSVWithOptions(file, ReadOptions{CountOnly: countOnly})
+				file.Close()
+				if err != nil {
+					b.Fatalf("failed to read and count domains: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark for reading customers and counting domains with and without an accurate "EstimatedRows"
+func BenchmarkReadCustomersFromCSVEstimatedRows(b *testing.B) {
+	const lines = 100000
+	input := generateCSV(lines, lines)
+
+	for _, estimate := range []int{0, lines} {
+		b.Run(fmt.Sprintf("ReadCustomersFromCSVWithOptions/EstimatedRows=%d", estimate), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{EstimatedRows: estimate})
+				if err != nil {
+					b.Fatalf("failed to read customers: %v", err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("ReadAndCountDomainsFromCSVWithOptions/EstimatedRows=%d", estimate), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{EstimatedRows: estimate})
+				if err != nil {
+					b.Fatalf("failed to read and count domains: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // Benchmark for the combined ReadCustomersFromCSV And CountDomains functions
 func BenchmarkReadCustomersFromCSVAndCountDomains(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -114,7 +176,87 @@ func BenchmarkReadCustomersFromCSVAndCountDomainsConcurrent(b *testing.B) {
 			providers = append(providers, c)
 		}
 
-		_ = CountDomainsConcurrent(providers)
+		_, _ = CountDomainsConcurrent(providers)
+	}
+}
+
+// Function "generateCSV" builds in-memory CSV customer data with given number of lines and distinct domains.
+func generateCSV(lines, domains int) string {
+	var sb strings.Builder
+
+	sb.WriteString("first_name,last_name,email,gender,ip_address\n")
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&sb, "First%d,Last%d,user%d@example%d.com,male,10.0.%d.%d\n", i, i, i, i%domains, (i/256)%256, i%256)
+	}
+
+	return sb.String()
+}
+
+// Function "generateProviders" builds in-memory customers with given number of distinct domains.
+func generateProviders(count, domains int) []DomainProvider {
+	providers := make([]DomainProvider, 0, count)
+	for i := 0; i < count; i++ {
+		providers = append(providers, customer{Email: email(fmt.Sprintf("user%d@example%d.com", i, i%domains))})
+	}
+
+	return providers
+}
+
+// Benchmark for CountDomains and CountDomainsConcurrent over datasets with different domain cardinality
+func BenchmarkCountDomainsCardinality(b *testing.B) {
+	for _, cardinality := range []int{10, 1000, 100000, 1000000} {
+		providers := generateProviders(1000000, cardinality)
+
+		b.Run(fmt.Sprintf("CountDomains/domains=%d", cardinality), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				CountDomains(providers)
+			}
+		})
+
+		b.Run(fmt.Sprintf("CountDomainsConcurrent/domains=%d", cardinality), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = CountDomainsConcurrent(providers)
+			}
+		})
+	}
+}
+
+// Benchmark for merging counts of many workers on a high-cardinality dataset
+func BenchmarkCountDomainsConcurrentWorkers(b *testing.B) {
+	providers := generateProviders(1000000, 500000)
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = countDomainsConcurrent(providers, workers)
+			}
+		})
+	}
+}
+
+// Benchmark for allocations of ProcessCSVFile with and without reusing records
+func BenchmarkProcessCSVFileReuseRecord(b *testing.B) {
+	input := generateCSV(100000, 1000)
+
+	for _, reuseRecord := range []bool{false, true} {
+		b.Run(fmt.Sprintf("ReuseRecord=%v", reuseRecord), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				reader := csv.NewReader(strings.NewReader(input))
+				reader.ReuseRecord = reuseRecord
+
+				err := ProcessCSVFile(reader, func(csvLine []string, csvLineNumber int) error {
+					_, err := parseCustomerLine(csvLine, csvLineNumber)
+					return err
+				})
+				if err != nil {
+					b.Fatalf("failed to process file: %v", err)
+				}
+			}
+		})
 	}
 }
 
@@ -192,6 +334,16 @@ func TestEmailIsValid(t *testing.T) {
 			email: "",
 			want:  false,
 		},
+		{
+			name:  "Valid email with trailing dot",
+			email: "test@example.com.",
+			want:  true,
+		},
+		{
+			name:  "Invalid email with two trailing dots",
+			email: "test@example.com..",
+			want:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -220,6 +372,21 @@ func TestEmailExtractDomain(t *testing.T) {
 			email: "test@sub.example.com",
 			want:  "sub.example.com",
 		},
+		{
+			name:  "Mixed case domain",
+			email: "Test@Example.COM",
+			want:  "example.com",
+		},
+		{
+			name:  "Fully qualified domain with trailing dot",
+			email: "test@example.com.",
+			want:  "example.com",
+		},
+		{
+			name:  "Missing @",
+			email: "testexample.com",
+			want:  "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -263,6 +430,61 @@ func TestParseGender(t *testing.T) {
 			input: "",
 			want:  unknown,
 		},
+		{
+			name:  "Uppercase letter code",
+			input: "M",
+			want:  male,
+		},
+		{
+			name:  "Lowercase letter code",
+			input: "f",
+			want:  female,
+		},
+		{
+			name:  "Transgender letter code",
+			input: "T",
+			want:  transgender,
+		},
+		{
+			name:  "Numeric code",
+			input: "1",
+			want:  male,
+		},
+		{
+			name:  "Numeric code female",
+			input: "2",
+			want:  female,
+		},
+		{
+			name:  "Numeric code not known",
+			input: "0",
+			want:  unknown,
+		},
+		{
+			name:  "Leading space",
+			input: " Male",
+			want:  male,
+		},
+		{
+			name:  "Trailing space",
+			input: "FEMALE ",
+			want:  female,
+		},
+		{
+			name:  "Surrounding tabs",
+			input: "\tm\t",
+			want:  male,
+		},
+		{
+			name:  "Split word not joined",
+			input: "trans  gender",
+			want:  unknown,
+		},
+		{
+			name:  "Whitespace only",
+			input: "   ",
+			want:  unknown,
+		},
 	}
 
 	for _, tt := range tests {
@@ -275,6 +497,76 @@ func TestParseGender(t *testing.T) {
 	}
 }
 
+func TestGenderString(t *testing.T) {
+	for _, g := range []gender{unknown, male, female, transgender} {
+		if got := parseGender(g.String()); got != g {
+			t.Errorf("parseGender(%q) = %v, want %v", g.String(), got, g)
+		}
+	}
+}
+
+// Function "registerTestGender" registers a gender with "RegisterGender", removing it when the test finishes
+// so other tests see only the built-in genders.
+func registerTestGender(t *testing.T, name string) gender {
+	t.Helper()
+
+	registered := RegisterGender(name)
+	t.Cleanup(func() {
+		gendersMu.Lock()
+		defer gendersMu.Unlock()
+
+		if registered > transgender {
+			delete(genderMap, registeredGenderNames[registered])
+			delete(registeredGenderNames, registered)
+		}
+	})
+
+	return registered
+}
+
+func TestRegisterGender(t *testing.T) {
+	nonBinary := registerTestGender(t, "non-binary")
+	if nonBinary <= transgender {
+		t.Fatalf("RegisterGender(%q) = %d, want value after transgender", "non-binary", nonBinary)
+	}
+
+	tests := []struct {
+		name string
+		got  gender
+		want gender
+	}{
+		{name: "Registering again", got: RegisterGender("Non-Binary "), want: nonBinary},
+		{name: "Registering built-in", got: RegisterGender("female"), want: female},
+		{name: "Registering empty", got: RegisterGender("  "), want: unknown},
+		{name: "Registering unknown", got: RegisterGender(" Unknown"), want: unknown},
+		{name: "Parsing unknown", got: parseGender("unknown"), want: unknown},
+		{name: "Parsing registered", got: parseGender("non-binary"), want: nonBinary},
+		{name: "Parsing uppercase", got: parseGender(" NON-BINARY"), want: nonBinary},
+		{name: "Parsing unregistered", got: parseGender("nonbinary"), want: unknown},
+		{name: "Unassigned value", got: parseGender((nonBinary + 1000).String()), want: unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	if got := nonBinary.String(); got != "non-binary" {
+		t.Errorf("String() = %v, want %v", got, "non-binary")
+	}
+
+	got, err := parseCustomerLine([]string{"Alex", "Doe", "alex@example.com", "Non-Binary", "10.0.0.1"}, 2)
+	if err != nil {
+		t.Fatalf("parseCustomerLine() unexpected error: %v", err)
+	}
+	if got.Gender != nonBinary {
+		t.Errorf("parseCustomerLine() gender = %v, want %v", got.Gender, nonBinary)
+	}
+}
+
 func TestParseCustomerLine(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -292,7 +584,7 @@ func TestParseCustomerLine(t *testing.T) {
 				LastName:  "Last",
 				Email:     "first.last@example.com",
 				Gender:    male,
-				IPAddress: net.ParseIP("192.168.1.1"),
+				IPAddress: net.ParseIP("192.168.1.1").To4(),
 			},
 			wantErr: false,
 		},
@@ -320,6 +612,18 @@ func TestParseCustomerLine(t *testing.T) {
 			lineNum: 4,
 			wantErr: true,
 		},
+		{
+			name:    "Too few fields",
+			line:    []string{"First", "Last", "first.last@example.com"},
+			lineNum: 5,
+			wantErr: true,
+		},
+		{
+			name:    "No fields",
+			line:    []string{},
+			lineNum: 6,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -343,64 +647,92 @@ func TestParseCustomerLine(t *testing.T) {
 	}
 }
 
-func TestCountDomains(t *testing.T) {
+func FuzzParseCustomerLine(f *testing.F) {
+	f.Add("First,Last,first.last@example.com,male,192.168.1.1", 2)
+	f.Add("First,Last,first.last@example.com", 3)
+	f.Add("", 4)
+	f.Add(",,,,", 5)
+	f.Add("First,Last,\xff@example.com,\xfe,::1", 6)
+
+	f.Fuzz(func(t *testing.T, line string, lineNum int) {
+		csvLine := strings.Split(line, ",")
+
+		got, err := parseCustomerLine(csvLine, lineNum)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Errorf("parseCustomerLine(%q) error = %v, want ParseError", csvLine, err)
+			}
+			return
+		}
+
+		if !got.Email.isValid() || got.IPAddress == nil || got.FirstName == "" || got.LastName == "" {
+			t.Errorf("parseCustomerLine(%q) = %v, want a valid customer", csvLine, got)
+		}
+	})
+}
+
+func TestCustomerRegistrableDomain(t *testing.T) {
 	tests := []struct {
-		name      string
-		customers []customer
-		want      []domainCount
+		name    string
+		email   email
+		want    string
+		wantErr bool
 	}{
 		{
-			name: "Single domain",
-			customers: []customer{
-				{Email: "user1@example1.com"},
-				{Email: "user2@example1.com"},
-			},
-			want: []domainCount{
-				{Domain: "example1.com", Count: 2},
-			},
+			name:    "Plain domain",
+			email:   "user@example.com",
+			want:    "example.com",
+			wantErr: false,
 		},
 		{
-			name: "Multiple domains",
-			customers: []customer{
-				{Email: "user1@example1.com"},
-				{Email: "user2@example1.com"},
-				{Email: "user3@example2.com"},
-			},
-			want: []domainCount{
-				{Domain: "example1.com", Count: 2},
-				{Domain: "example2.com", Count: 1},
-			},
+			name:    "Subdomain",
+			email:   "user@mail.example.com",
+			want:    "example.com",
+			wantErr: false,
 		},
 		{
-			name:      "No customers",
-			customers: []customer{},
-			want:      []domainCount{},
+			name:    "Multi-level suffix",
+			email:   "user@foo.example.co.uk",
+			want:    "example.co.uk",
+			wantErr: false,
+		},
+		{
+			name:    "Multi-level suffix without subdomain",
+			email:   "user@example.com.au",
+			want:    "example.com.au",
+			wantErr: false,
+		},
+		{
+			name:    "Public suffix only",
+			email:   "user@co.uk",
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var providers []DomainProvider
-			for _, c := range tt.customers {
-				providers = append(providers, c)
-			}
+			c := customer{Email: tt.email}
+			got, err := c.RegistrableDomain()
 
-			got := CountDomains(providers)
+			if err != nil && !tt.wantErr {
+				t.Errorf("customer.RegistrableDomain() unexpected error: %v", err)
+				return
+			}
 
-			//special case for no data
-			if len(got) == 0 && len(tt.want) == 0 {
+			if err == nil && tt.wantErr {
+				t.Errorf("customer.RegistrableDomain() expected error, got none")
 				return
 			}
 
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CountDomains() = %v, want %v", got, tt.want)
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("customer.RegistrableDomain() for email %v = %v, want %v", tt.email, got, tt.want)
 			}
 		})
 	}
 }
 
-// ! RUN TEST WITH RACE DETECTOR
-func TestCountDomainsConcurrent(t *testing.T) {
+func TestCountDomains(t *testing.T) {
 	tests := []struct {
 		name      string
 		customers []customer
@@ -420,8 +752,8 @@ func TestCountDomainsConcurrent(t *testing.T) {
 			name: "Multiple domains",
 			customers: []customer{
 				{Email: "user1@example1.com"},
-				{Email: "user2@example2.com"},
-				{Email: "user3@example1.com"},
+				{Email: "user2@example1.com"},
+				{Email: "user3@example2.com"},
 			},
 			want: []domainCount{
 				{Domain: "example1.com", Count: 2},
@@ -442,7 +774,7 @@ func TestCountDomainsConcurrent(t *testing.T) {
 				providers = append(providers, c)
 			}
 
-			got := CountDomainsConcurrent(providers)
+			got := CountDomains(providers)
 
 			//special case for no data
 			if len(got) == 0 && len(tt.want) == 0 {
@@ -456,108 +788,2970 @@ func TestCountDomainsConcurrent(t *testing.T) {
 	}
 }
 
-func TestReadCustomersFromCSV(t *testing.T) {
+func TestDomainCountsToMap(t *testing.T) {
+	domainCounts := map[string]int{
+		"example1.com": 3,
+		"example2.com": 1,
+		"example3.com": 1,
+	}
+
+	counts := sortDomainCounts(domainCounts)
+
+	got := DomainCountsToMap(counts)
+	if !reflect.DeepEqual(got, domainCounts) {
+		t.Errorf("DomainCountsToMap() = %v, want %v", got, domainCounts)
+	}
+
+	if roundTrip := sortDomainCounts(got); !reflect.DeepEqual(roundTrip, counts) {
+		t.Errorf("sortDomainCounts(DomainCountsToMap()) = %v, want %v", roundTrip, counts)
+	}
+
+	if got := DomainCountsToMap(nil); len(got) != 0 {
+		t.Errorf("DomainCountsToMap(nil) = %v, want empty map", got)
+	}
+}
+
+func TestSortCustomers(t *testing.T) {
+	alice := customer{FirstName: "Alice", LastName: "Smith", Email: "alice@zeta.com", IPAddress: net.ParseIP("10.0.0.2").To4()}
+	bob := customer{FirstName: "Bob", LastName: "Jones", Email: "bob@Alpha.com", IPAddress: net.ParseIP("2001:db8::1")}
+	carol := customer{FirstName: "Carol", LastName: "Smith", Email: "carol@beta.com", IPAddress: net.ParseIP("10.0.0.1").To4()}
+	dave := customer{FirstName: "Dave", LastName: "Adams", Email: "dave@alpha.com", IPAddress: nil}
+
 	tests := []struct {
-		name    string
-		input   string
-		want    []customer
-		wantErr bool
+		name string
+		by   CustomerSortField
+		want []customer
 	}{
 		{
-			name: "Valid CSV data",
-			input: `first_name,last_name,email,gender,ip_address
-First,Last,first.last@example.com,male,192.168.1.1
-First,Last,first.last@example.com,female,192.168.1.2`,
-			want: []customer{
-				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1")},
-				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: female, IPAddress: net.ParseIP("192.168.1.2")},
-			},
-			wantErr: false,
+			name: "By last name, stable on ties",
+			by:   SORT_BY_LAST_NAME,
+			want: []customer{dave, bob, alice, carol},
 		},
 		{
-			name: "Invalid CSV data - bad email",
-			input: `first_name,last_name,email,gender,ip_address
-			John,Doe,bademail,male,192.168.1.1`,
-			wantErr: true,
+			name: "By first name",
+			by:   SORT_BY_FIRST_NAME,
+			want: []customer{alice, bob, carol, dave},
+		},
+		{
+			name: "By email domain",
+			by:   SORT_BY_EMAIL_DOMAIN,
+			want: []customer{bob, dave, carol, alice},
+		},
+		{
+			name: "By IP address",
+			by:   SORT_BY_IP_ADDRESS,
+			want: []customer{dave, carol, alice, bob},
+		},
+		{
+			name: "Unknown field",
+			by:   CustomerSortField(-1),
+			want: []customer{alice, bob, carol, dave},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := strings.NewReader(tt.input)
-			got, err := ReadCustomersFromCSV(r)
+			customers := []customer{alice, bob, carol, dave}
 
-			if err != nil && !tt.wantErr {
-				t.Errorf("ReadCustomersFromCSV() unexpected error: %v", err)
-				return
+			SortCustomers(customers, tt.by)
+			if !reflect.DeepEqual(customers, tt.want) {
+				t.Errorf("SortCustomers() = %v, want %v", customers, tt.want)
 			}
+		})
+	}
+}
 
-			if err == nil && tt.wantErr {
-				t.Errorf("ReadCustomersFromCSV() expected error, got none")
-				return
-			}
+func TestSortDomainCountsFunc(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example.com", Count: 3},
+		{Domain: "a.io", Count: 1},
+		{Domain: "longer-example.org", Count: 5},
+		{Domain: "b.io", Count: 2},
+	}
 
-			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ReadCustomersFromCSV() got = %v, want %v", got, tt.want)
-			}
-		})
+	SortDomainCountsFunc(counts, func(a, b domainCount) bool {
+		return len(a.Domain) < len(b.Domain)
+	})
+
+	want := []domainCount{
+		{Domain: "a.io", Count: 1},
+		{Domain: "b.io", Count: 2},
+		{Domain: "example.com", Count: 3},
+		{Domain: "longer-example.org", Count: 5},
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("SortDomainCountsFunc() = %v, want %v", counts, want)
 	}
 }
 
-func TestReadAndCountDomainsFromCSV(t *testing.T) {
+func TestUniqueDomains(t *testing.T) {
 	tests := []struct {
-		name    string
-		input   string
-		want    []domainCount
-		wantErr bool
+		name      string
+		customers []customer
+		want      []string
+	}{
+		{
+			name: "Duplicated domains",
+			customers: []customer{
+				{Email: "user1@foo.org"},
+				{Email: "user2@example.com"},
+				{Email: "user3@foo.org"},
+				{Email: "user4@bar.net"},
+				{Email: "user5@example.com"},
+			},
+			want: []string{"bar.net", "example.com", "foo.org"},
+		},
+		{
+			name:      "No customers",
+			customers: []customer{},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []DomainProvider
+			for _, c := range tt.customers {
+				providers = append(providers, c)
+			}
+
+			got := UniqueDomains(providers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UniqueDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainCounter(t *testing.T) {
+	var counter DomainCounter
+
+	counter.Add("example1.com")
+	counter.Add("example2.com")
+	counter.Add("example1.com")
+
+	if counter.Len() != 2 {
+		t.Errorf("DomainCounter.Len() = %d, want 2", counter.Len())
+	}
+
+	want := []domainCount{
+		{Domain: "example1.com", Count: 2},
+		{Domain: "example2.com", Count: 1},
+	}
+	if got := counter.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DomainCounter.Counts() = %v, want %v", got, want)
+	}
+
+	counter.Reset()
+
+	if counter.Len() != 0 {
+		t.Errorf("DomainCounter.Len() after Reset() = %d, want 0", counter.Len())
+	}
+
+	if got := counter.Counts(); len(got) != 0 {
+		t.Errorf("DomainCounter.Counts() after Reset() = %v, want empty", got)
+	}
+
+	counter.Add("example3.com")
+
+	want = []domainCount{{Domain: "example3.com", Count: 1}}
+	if got := counter.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DomainCounter.Counts() after reuse = %v, want %v", got, want)
+	}
+}
+
+// ! RUN TEST WITH RACE DETECTOR
+func TestCountDomainsConcurrent(t *testing.T) {
+	tests := []struct {
+		name      string
+		customers []customer
+		want      []domainCount
+	}{
+		{
+			name: "Single domain",
+			customers: []customer{
+				{Email: "user1@example1.com"},
+				{Email: "user2@example1.com"},
+			},
+			want: []domainCount{
+				{Domain: "example1.com", Count: 2},
+			},
+		},
+		{
+			name: "Multiple domains",
+			customers: []customer{
+				{Email: "user1@example1.com"},
+				{Email: "user2@example2.com"},
+				{Email: "user3@example1.com"},
+			},
+			want: []domainCount{
+				{Domain: "example1.com", Count: 2},
+				{Domain: "example2.com", Count: 1},
+			},
+		},
+		{
+			name: "Mixed case domains",
+			customers: []customer{
+				{Email: "user1@Example1.com"},
+				{Email: "user2@EXAMPLE1.COM"},
+				{Email: "user3@example1.com."},
+				{Email: "user4@example2.com"},
+			},
+			want: []domainCount{
+				{Domain: "example1.com", Count: 3},
+				{Domain: "example2.com", Count: 1},
+			},
+		},
+		{
+			name:      "No customers",
+			customers: []customer{},
+			want:      []domainCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []DomainProvider
+			for _, c := range tt.customers {
+				providers = append(providers, c)
+			}
+
+			got, err := CountDomainsConcurrent(providers)
+			if err != nil {
+				t.Fatalf("CountDomainsConcurrent() unexpected error: %v", err)
+			}
+
+			//special case for no data
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Type "signup" is a second "DomainProvider" implementation used to test counting of mixed provider types.
+type signup struct {
+	host string
+}
+
+func (s signup) GetDomain() string {
+	return s.host
+}
+
+func TestCountDomainsMixedProviders(t *testing.T) {
+	providers := []DomainProvider{
+		customer{Email: "user1@example1.com"},
+		signup{host: "example1.com"},
+		&signup{host: "example2.com"},
+		customer{Email: "user2@example2.com"},
+		signup{host: "example1.com"},
+		customer{Email: "user3@example3.com"},
+	}
+	want := []domainCount{
+		{Domain: "example1.com", Count: 3},
+		{Domain: "example2.com", Count: 2},
+		{Domain: "example3.com", Count: 1},
+	}
+
+	countFuncs := map[string]func([]DomainProvider) []domainCount{
+		"CountDomains": CountDomains,
+		"CountDomainsConcurrent": func(providers []DomainProvider) []domainCount {
+			counts, err := CountDomainsConcurrent(providers)
+			if err != nil {
+				t.Fatalf("CountDomainsConcurrent() unexpected error: %v", err)
+			}
+			return counts
+		},
+	}
+
+	for name, countFunc := range countFuncs {
+		t.Run(name, func(t *testing.T) {
+			got := countFunc(providers)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s() = %v, want %v", name, got, want)
+			}
+		})
+	}
+}
+
+func TestCountDomainStrings(t *testing.T) {
+	providers := []DomainProvider{
+		DomainString("example1.com"),
+		DomainString("Example1.COM"),
+		DomainString("example2.com."),
+		DomainString("example2.com"),
+		customer{Email: "user@example1.com"},
+		DomainString("example3.com"),
+	}
+	want := []domainCount{
+		{Domain: "example1.com", Count: 3},
+		{Domain: "example2.com", Count: 2},
+		{Domain: "example3.com", Count: 1},
+	}
+
+	if got := CountDomains(providers); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomains() = %v, want %v", got, want)
+	}
+
+	got, err := CountDomainsConcurrent(providers)
+	if err != nil {
+		t.Fatalf("CountDomainsConcurrent() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomainsConcurrent() = %v, want %v", got, want)
+	}
+}
+
+// ! RUN TEST WITH RACE DETECTOR
+func TestCountDomainsConcurrentChunking(t *testing.T) {
+	domains := []string{"example1.com", "example2.com", "example3.com"}
+
+	for workers := 1; workers <= 8; workers++ {
+		for size := 0; size <= 25; size++ {
+			var providers []DomainProvider
+			for i := 0; i < size; i++ {
+				providers = append(providers, customer{Email: email(fmt.Sprintf("user%d@%s", i, domains[i%len(domains)]))})
+			}
+
+			got, err := countDomainsConcurrent(providers, workers)
+			if err != nil {
+				t.Fatalf("countDomainsConcurrent() unexpected error: %v", err)
+			}
+			want := CountDomains(providers)
+
+			total := 0
+			for _, dc := range got {
+				total += dc.Count
+			}
+
+			if total != size {
+				t.Errorf("countDomainsConcurrent() with %d providers on %d workers counted %d, want %d", size, workers, total, size)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("countDomainsConcurrent() with %d providers on %d workers = %v, want %v", size, workers, got, want)
+			}
+		}
+	}
+}
+
+func TestCountDomainsConcurrentWorkers(t *testing.T) {
+	providers := generateProviders(10001, 37)
+	want, err := CountDomainsConcurrentWorkers(providers, 3)
+	if err != nil {
+		t.Fatalf("CountDomainsConcurrentWorkers() unexpected error: %v", err)
+	}
+
+	for run := 0; run < 20; run++ {
+		if got, _ := CountDomainsConcurrentWorkers(providers, 3); !reflect.DeepEqual(got, want) {
+			t.Fatalf("CountDomainsConcurrentWorkers() on run %d = %v, want %v", run, got, want)
+		}
+	}
+
+	if got := CountDomains(providers); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomainsConcurrentWorkers() = %v, want CountDomains() result %v", want, got)
+	}
+}
+
+func TestCountDomainsConcurrentWorkersHuge(t *testing.T) {
+	providers := generateProviders(1001, 13)
+	want := CountDomains(providers)
+
+	for _, workers := range []int{1 << 40, math.MaxInt} {
+		got, err := CountDomainsConcurrentWorkers(providers, workers)
+		if err != nil {
+			t.Fatalf("CountDomainsConcurrentWorkers(%d) unexpected error: %v", workers, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CountDomainsConcurrentWorkers(%d) = %v, want %v", workers, got, want)
+		}
+
+		if got, err := CountDomainsConcurrentWorkers(nil, workers); err != nil || len(got) != 0 {
+			t.Errorf("CountDomainsConcurrentWorkers(nil, %d) = %v, %v, want no counts", workers, got, err)
+		}
+	}
+}
+
+// Type "panickingProvider" is a "DomainProvider" that panics, like one dereferencing missing data would.
+type panickingProvider struct{}
+
+func (panickingProvider) GetDomain() string {
+	panic("missing email")
+}
+
+func TestCountDomainsConcurrentPanic(t *testing.T) {
+	for _, workers := range []int{1, 2, 4} {
+		providers := generateProviders(100, 5)
+		providers[50] = panickingProvider{}
+		providers = append(providers, panickingProvider{})
+
+		got, err := countDomainsConcurrent(providers, workers)
+		if !errors.Is(err, ErrProviderPanic) {
+			t.Errorf("countDomainsConcurrent() on %d workers error = %v, want ErrProviderPanic", workers, err)
+		}
+		if got != nil {
+			t.Errorf("countDomainsConcurrent() on %d workers = %v, want nil", workers, got)
+		}
+	}
+
+	if _, err := CountDomainsConcurrent([]DomainProvider{panickingProvider{}}); !errors.Is(err, ErrProviderPanic) {
+		t.Errorf("CountDomainsConcurrent() error = %v, want ErrProviderPanic", err)
+	}
+}
+
+// Type "activeCountingProvider" is a "DomainProvider" recording the highest number of workers calling it at once.
+// Calls of a single worker are sequential, so the number of concurrent calls is the number of running workers.
+type activeCountingProvider struct {
+	active    *atomic.Int64
+	maxActive *atomic.Int64
+}
+
+func (p activeCountingProvider) GetDomain() string {
+	active := p.active.Add(1)
+	defer p.active.Add(-1)
+
+	for {
+		maxActive := p.maxActive.Load()
+		if active <= maxActive || p.maxActive.CompareAndSwap(maxActive, active) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	return "example.com"
+}
+
+func TestCountDomainsConcurrentLimit(t *testing.T) {
+	for _, limit := range []int{1, 2, 4} {
+		var active, maxActive atomic.Int64
+		providers := make([]DomainProvider, 64)
+		for i := range providers {
+			providers[i] = activeCountingProvider{active: &active, maxActive: &maxActive}
+		}
+
+		got, err := CountDomainsConcurrentLimit(providers, len(providers), limit)
+		if err != nil {
+			t.Fatalf("CountDomainsConcurrentLimit() unexpected error: %v", err)
+		}
+
+		want := []domainCount{{Domain: "example.com", Count: len(providers)}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CountDomainsConcurrentLimit() = %v, want %v", got, want)
+		}
+		if maxActive.Load() > int64(limit) {
+			t.Errorf("CountDomainsConcurrentLimit() ran %d workers at once, want at most %d", maxActive.Load(), limit)
+		}
+	}
+}
+
+func TestCountDomainsConcurrentMixedCase(t *testing.T) {
+	spellings := []string{"example.com", "Example.com", "EXAMPLE.COM", "example.COM.", "foo.org", "Foo.Org"}
+
+	var providers []DomainProvider
+	for i := 0; i < 1200; i++ {
+		providers = append(providers, customer{Email: email(fmt.Sprintf("user%d@%s", i, spellings[i%len(spellings)]))})
+	}
+
+	want := []domainCount{
+		{Domain: "example.com", Count: 800},
+		{Domain: "foo.org", Count: 400},
+	}
+
+	for workers := 1; workers <= 8; workers++ {
+		if got, _ := countDomainsConcurrent(providers, workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("countDomainsConcurrent() on %d workers = %v, want %v", workers, got, want)
+		}
+	}
+
+	if got := CountDomains(providers); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestCountDomainsConcurrentHighCardinality(t *testing.T) {
+	providers := generateProviders(20000, 5000)
+	want := CountDomains(providers)
+
+	for _, workers := range []int{1, 3, DOMAIN_COUNT_SHARDS, 2 * DOMAIN_COUNT_SHARDS} {
+		if got, _ := countDomainsConcurrent(providers, workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("countDomainsConcurrent() on %d workers differs from CountDomains()", workers)
+		}
+	}
+}
+
+func FuzzCountDomainsConcurrent(f *testing.F) {
+	f.Add("example1.com,example2.com,example1.com", 2)
+	f.Add("b.com,a.com,c.com,a.com,b.com,c.com,d.com", 3)
+	f.Add("", 4)
+	f.Add(",,,", 1)
+
+	f.Fuzz(func(t *testing.T, domains string, workers int) {
+		workers = int(uint(workers)%16) + 1
+
+		var providers []DomainProvider
+		for _, domain := range strings.Split(domains, ",") {
+			providers = append(providers, signup{host: domain})
+		}
+
+		got, err := countDomainsConcurrent(providers, workers)
+		if err != nil {
+			t.Fatalf("countDomainsConcurrent() unexpected error: %v", err)
+		}
+		want := CountDomains(providers)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("countDomainsConcurrent() on %d workers = %v, want %v", workers, got, want)
+		}
+	})
+}
+
+func TestAppendCustomersFromCSV(t *testing.T) {
+	existing := customer{FirstName: "Existing", LastName: "Customer", Email: "existing@example.com", Gender: male, IPAddress: net.ParseIP("10.0.0.1").To4()}
+	first := customer{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1").To4()}
+	second := customer{FirstName: "First", LastName: "Last", Email: "second.last@example.org", Gender: female, IPAddress: net.ParseIP("192.168.1.2").To4()}
+
+	valid := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.org,female,192.168.1.2`
+	invalid := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,not-an-email,female,192.168.1.2`
+
+	tests := []struct {
+		name    string
+		dst     []customer
+		input   string
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name:    "Existing elements preserved",
+			dst:     []customer{existing},
+			input:   valid,
+			want:    []customer{existing, first, second},
+			wantErr: false,
+		},
+		{
+			name:    "Nil destination",
+			dst:     nil,
+			input:   valid,
+			want:    []customer{first, second},
+			wantErr: false,
+		},
+		{
+			name:    "Nil destination without data",
+			dst:     nil,
+			input:   "",
+			want:    []customer{},
+			wantErr: false,
+		},
+		{
+			name:    "Error keeps original length",
+			dst:     []customer{existing},
+			input:   invalid,
+			want:    []customer{existing},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendCustomersFromCSV(tt.dst, strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AppendCustomersFromCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AppendCustomersFromCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Reused buffer", func(t *testing.T) {
+		buffer := make([]customer, 0, 4)
+
+		got, err := AppendCustomersFromCSV(buffer, strings.NewReader(valid))
+		if err != nil {
+			t.Fatalf("AppendCustomersFromCSV() unexpected error: %v", err)
+		}
+		if &got[0] != &buffer[:1][0] {
+			t.Errorf("AppendCustomersFromCSV() reallocated a buffer with enough capacity")
+		}
+	})
+}
+
+func TestReadCustomersFromCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name: "Valid CSV data",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,first.last@example.com,female,192.168.1.2`,
+			want: []customer{
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1").To4()},
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: female, IPAddress: net.ParseIP("192.168.1.2").To4()},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid CSV data - bad email",
+			input: `first_name,last_name,email,gender,ip_address
+			John,Doe,bademail,male,192.168.1.1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.input)
+			got, err := ReadCustomersFromCSV(r)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadCustomersFromCSV() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadCustomersFromCSV() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCustomersFromCSV() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAndCountDomainsFromCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []domainCount
+		wantErr bool
 	}{
 		{
 			name: "Valid CSV data - single domain",
 			input: `first_name,last_name,email,gender,ip_address
 First,Last,first.last@example.com,male,192.168.1.1
-First,Last,second.last@example.com,female,192.168.1.2`,
+First,Last,second.last@example.com,female,192.168.1.2`,
+			want: []domainCount{
+				{Domain: "example.com", Count: 2},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid CSV data - multiple domains",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example1.com,male,192.168.1.1
+First,Last,second.last@example2.com,female,192.168.1.2
+First,Last,second.last@example1.com,female,192.168.1.2`,
+			want: []domainCount{
+				{Domain: "example1.com", Count: 2},
+				{Domain: "example2.com", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid CSV data - equivalent domain spellings",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,user@example.com.,male,192.168.1.1
+First,Last,user@example.com,female,192.168.1.2
+First,Last,user@EXAMPLE.com,female,192.168.1.3`,
+			want: []domainCount{
+				{Domain: "example.com", Count: 3},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid CSV data - bad email",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,user1@@example.com,male,192.168.1.1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.input)
+			got, err := ReadAndCountDomainsFromCSV(r)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSV() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSV() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSV() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{
+			name:   "Comma delimited",
+			sample: "first_name,last_name,email,gender,ip_address\nFirst,Last,first.last@example.com,male,192.168.1.1",
+			want:   ',',
+		},
+		{
+			name:   "Semicolon delimited",
+			sample: "first_name;last_name;email;gender;ip_address\nFirst;Last;first.last@example.com;male;192.168.1.1",
+			want:   ';',
+		},
+		{
+			name:   "Tab delimited",
+			sample: "first_name\tlast_name\temail\tgender\tip_address\nFirst\tLast\tfirst.last@example.com\tmale\t192.168.1.1",
+			want:   '\t',
+		},
+		{
+			name:   "Delimiters inside quotes are ignored",
+			sample: "\"a,b,c\";\"d,e\";f\n",
+			want:   ';',
+		},
+		{
+			name:   "No delimiter",
+			sample: "email",
+			want:   ',',
+		},
+		{
+			name:   "Empty sample",
+			sample: "",
+			want:   ',',
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectDelimiter([]byte(tt.sample))
+			if got != tt.want {
+				t.Errorf("DetectDelimiter(%q) = %q, want %q", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzReadAndCountDomainsFromCSV(f *testing.F) {
+	f.Add([]byte("first_name,last_name,email,gender,ip_address\nFirst,Last,first.last@example.com,male,192.168.1.1\n"))
+	f.Add([]byte("first_name,last_name,email,gender,ip_address\nFirst,\"Last,first.last@example.com,male,192.168.1.1"))
+	f.Add([]byte("first_name,last_name,email\nFirst,Last,first.last@example.com\n"))
+	f.Add([]byte("first_name,last_name,email,gender,ip_address\nFirst,La\x00st,first.last@example.com,male,192.168.1.1\n"))
+	f.Add([]byte(""))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		got, err := ReadAndCountDomainsFromCSV(bytes.NewReader(input))
+		if err == nil {
+			for _, dc := range got {
+				if dc.Count < 1 {
+					t.Errorf("ReadAndCountDomainsFromCSV() counted domain %q %d times", dc.Domain, dc.Count)
+				}
+			}
+		}
+
+		opts := ReadOptions{AutoDelimiter: true, MapColumnsByHeader: true, SkipInvalid: true, IgnoreFooter: true}
+		want, wantErr := ReadAndCountDomainsFromCSVWithOptions(bytes.NewReader(input), opts)
+
+		opts.CountOnly = true
+		got, err = ReadAndCountDomainsFromCSVWithOptions(bytes.NewReader(input), opts)
+		if (err != nil) != (wantErr != nil) {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly error = %v, want %v", err, wantErr)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestReadAndCountDomainsFromCSVWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name: "Semicolon delimiter",
+			input: `first_name;last_name;email;gender;ip_address
+First;Last;first.last@example.com;male;192.168.1.1`,
+			opts: ReadOptions{Delimiter: ';'},
+			want: []domainCount{
+				{Domain: "example.com", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Auto delimiter - tab",
+			input:   "first_name\tlast_name\temail\tgender\tip_address\nFirst\tLast\tfirst.last@example.com\tmale\t192.168.1.1",
+			opts:    ReadOptions{AutoDelimiter: true},
+			want:    []domainCount{{Domain: "example.com", Count: 1}},
+			wantErr: false,
+		},
+		{
+			name: "Auto delimiter - semicolon",
+			input: `first_name;last_name;email;gender;ip_address
+First;Last;first.last@example1.com;male;192.168.1.1
+First;Last;second.last@example1.com;female;192.168.1.2`,
+			opts:    ReadOptions{AutoDelimiter: true},
+			want:    []domainCount{{Domain: "example1.com", Count: 2}},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.input)
+			got, err := ReadAndCountDomainsFromCSVWithOptions(r, tt.opts)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAndCountDomainsFromCSVCountOnly(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@Example.com,male,192.168.1.1
+First,Last,second.last@example.com.,female,::ffff:192.168.1.2
+First,Last,third.last@example.org,female,2001:db8::1
+First,Last,not-an-email,male,192.168.1.3
+First,,fourth.last@example.org,male,192.168.1.4
+First,Last,fifth.last@example.org,male,example.org
+First,Last,sixth.last@example.org,male,fe80::1%eth0
+First,Last,seventh.last@example.net,male,10.0.0.1`
+
+	tests := []struct {
+		name string
+		opts ReadOptions
+	}{
+		{
+			name: "Skip invalid",
+			opts: ReadOptions{SkipInvalid: true},
+		},
+		{
+			name: "Strict",
+			opts: ReadOptions{},
+		},
+		{
+			name: "Lenient domain and strip www",
+			opts: ReadOptions{SkipInvalid: true, LenientDomain: true, StripWWW: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wantStats, gotStats ReadStats
+
+			tt.opts.Stats = &wantStats
+			want, wantErr := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+
+			tt.opts.CountOnly = true
+			tt.opts.Stats = &gotStats
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+
+			if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() error = %v, want %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, want)
+			}
+			if gotStats != wantStats {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() stats = %+v, want %+v", gotStats, wantStats)
+			}
+		})
+	}
+}
+
+func TestCountDomainsFromDir(t *testing.T) {
+	header := "first_name,last_name,email,gender,ip_address\n"
+	dir := t.TempDir()
+
+	writeFile := func(t *testing.T, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	writeFile(t, "first.csv", header+"First,Last,first.last@example1.com,male,192.168.1.1\nFirst,Last,second.last@example2.com,female,192.168.1.2\n")
+	writeFile(t, "notes.txt", header+"First,Last,first.last@ignored.com,male,192.168.1.1\n")
+	if err := os.Mkdir(filepath.Join(dir, "nested.csv"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write([]byte(header + "First,Last,third.last@example1.com,male,192.168.1.3\n"))
+	gzipWriter.Close()
+	writeFile(t, "second.csv", gzipped.String())
+
+	t.Run("Matching files aggregated", func(t *testing.T) {
+		got, err := CountDomainsFromDir(dir, "*.csv")
+		if err != nil {
+			t.Fatalf("CountDomainsFromDir() unexpected error: %v", err)
+		}
+
+		want := []domainCount{
+			{Domain: "example1.com", Count: 2},
+			{Domain: "example2.com", Count: 1},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("CountDomainsFromDir() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("No matching files", func(t *testing.T) {
+		got, err := CountDomainsFromDir(dir, "*.tsv")
+		if err != nil {
+			t.Fatalf("CountDomainsFromDir() unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("CountDomainsFromDir() = %v, want no counts", got)
+		}
+	})
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		if _, err := CountDomainsFromDir(dir, "[.csv"); !errors.Is(err, filepath.ErrBadPattern) {
+			t.Errorf("CountDomainsFromDir() error = %v, want %v", err, filepath.ErrBadPattern)
+		}
+	})
+
+	t.Run("Failing file reported", func(t *testing.T) {
+		writeFile(t, "third.csv", header+"First,Last,not-an-email,male,192.168.1.1\n")
+
+		_, err := CountDomainsFromDir(dir, "*.csv")
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || !strings.Contains(err.Error(), "third.csv") {
+			t.Errorf("CountDomainsFromDir() error = %v, want ParseError naming third.csv", err)
+		}
+	})
+
+	t.Run("Corrupt gzip file reported once", func(t *testing.T) {
+		writeFile(t, "fourth.csv", "\x1f\x8bnot really gzip")
+
+		_, err := CountDomainsFromDir(dir, "*.csv")
+
+		if !errors.Is(err, gzip.ErrHeader) || strings.Count(err.Error(), "fourth.csv") != 1 {
+			t.Errorf("CountDomainsFromDir() error = %v, want %v naming fourth.csv once", err, gzip.ErrHeader)
+		}
+	})
+}
+
+// Type "recordingReadCloser" wraps a reader, recording whether it was closed and returning "closeErr" from "Close".
+type recordingReadCloser struct {
+	io.Reader
+	closed   bool
+	closeErr error
+}
+
+func (rc *recordingReadCloser) Close() error {
+	rc.closed = true
+	return rc.closeErr
+}
+
+func TestReadAndCountDomainsFromReaderWithClose(t *testing.T) {
+	valid := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1`
+	invalid := `first_name,last_name,email,gender,ip_address
+First,Last,not-an-email,male,192.168.1.1`
+	errClose := errors.New("close failed")
+
+	tests := []struct {
+		name     string
+		input    string
+		closeErr error
+		want     []domainCount
+		wantErr  bool
+	}{
+		{
+			name:     "Closed after reading",
+			input:    valid,
+			closeErr: nil,
+			want:     []domainCount{{Domain: "example.com", Count: 1}},
+			wantErr:  false,
+		},
+		{
+			name:     "Closed after failed reading",
+			input:    invalid,
+			closeErr: nil,
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "Close error returned",
+			input:    valid,
+			closeErr: errClose,
+			want:     nil,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &recordingReadCloser{Reader: strings.NewReader(tt.input), closeErr: tt.closeErr}
+
+			got, err := ReadAndCountDomainsFromReaderWithClose(rc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAndCountDomainsFromReaderWithClose() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.closeErr != nil && !errors.Is(err, tt.closeErr) {
+				t.Errorf("ReadAndCountDomainsFromReaderWithClose() error = %v, want %v", err, tt.closeErr)
+			}
+			if !rc.closed {
+				t.Errorf("ReadAndCountDomainsFromReaderWithClose() did not close the reader")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromReaderWithClose() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Any "io.ReadCloser" works, e.g. "GetObjectOutput.Body" of AWS SDK:
+//
+//	object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+//	if err != nil {
+//		return err
+//	}
+//	counts, err := ReadAndCountDomainsFromReaderWithClose(object.Body)
+func ExampleReadAndCountDomainsFromReaderWithClose() {
+	body := io.NopCloser(strings.NewReader(`first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.com,female,192.168.1.2`))
+
+	counts, err := ReadAndCountDomainsFromReaderWithClose(body)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(counts)
+	// Output: [{example.com 2}]
+}
+
+func TestReadAndCountDomainsFromTSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name: "Tab separated",
+			input: "first_name\tlast_name\temail\tgender\tip_address\n" +
+				"First\tLast\tfirst.last@example1.com\tmale\t192.168.1.1\n" +
+				"First, Jr.\tLast\tsecond.last@example1.com\tfemale\t192.168.1.2\n" +
+				"First\tLast\tthird.last@example2.com\tfemale\t192.168.1.3\n",
+			want: []domainCount{
+				{Domain: "example1.com", Count: 2},
+				{Domain: "example2.com", Count: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Comma separated",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example1.com,male,192.168.1.1`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromTSV(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAndCountDomainsFromTSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromTSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAndCountDomainsFromFile(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example1.com,male,192.168.1.1
+First,Last,second.last@example2.com,female,192.168.1.2
+First,Last,second.last@example1.com,female,192.168.1.2`
+	want := []domainCount{
+		{Domain: "example1.com", Count: 2},
+		{Domain: "example2.com", Count: 1},
+	}
+
+	dir := t.TempDir()
+
+	plainPath := filepath.Join(dir, "customers.csv")
+	if err := os.WriteFile(plainPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	gzipPath := filepath.Join(dir, "customers.csv.gz")
+	gzipFile, err := os.Create(gzipPath)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	gzipWriter := gzip.NewWriter(gzipFile)
+	if _, err := gzipWriter.Write([]byte(input)); err != nil {
+		t.Fatalf("failed to write gzip file: %v", err)
+	}
+	gzipWriter.Close()
+	gzipFile.Close()
+
+	gzipData, err := os.ReadFile(gzipPath)
+	if err != nil {
+		t.Fatalf("failed to read gzip file: %v", err)
+	}
+
+	truncatedPath := filepath.Join(dir, "truncated.csv.gz")
+	if err := os.WriteFile(truncatedPath, gzipData[:len(gzipData)-4], 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// the trailer ends with CRC-32 and size of the uncompressed data
+	corruptData := bytes.Clone(gzipData)
+	corruptData[len(corruptData)-8] ^= 0xff
+	corruptPath := filepath.Join(dir, "corrupt.csv.gz")
+	if err := os.WriteFile(corruptPath, corruptData, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name:    "Plain CSV file",
+			path:    plainPath,
+			want:    want,
+			wantErr: false,
+		},
+		{
+			name:    "Gzip compressed CSV file",
+			path:    gzipPath,
+			want:    want,
+			wantErr: false,
+		},
+		{
+			name:    "Missing file",
+			path:    filepath.Join(dir, "missing.csv"),
+			wantErr: true,
+		},
+		{
+			name:    "Truncated gzip file",
+			path:    truncatedPath,
+			wantErr: true,
+		},
+		{
+			name:    "Gzip file with bad checksum",
+			path:    corruptPath,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromFile(tt.path)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromFile() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromFile() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromFile() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Type "failingReader" fails the test when read, proving that input past a certain point is never consumed.
+type failingReader struct {
+	t *testing.T
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	f.t.Errorf("input read past the first data row")
+	return 0, io.EOF
+}
+
+func TestPrevalidateFirstRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name: "Misaligned columns",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,192.168.1.1,male,first.last@example.com
+`,
+			wantErr: "first data row failed prevalidation, check the file schema: invalid email at line 2: 192.168.1.1",
+		},
+		{
+			name: "Too few fields",
+			input: `email
+first.last@example.com
+`,
+			wantErr: "first data row failed prevalidation at line 2: expected 5 fields, got 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := io.MultiReader(strings.NewReader(tt.input), failingReader{t: t})
+			_, err := ReadAndCountDomainsFromCSVWithOptions(r, ReadOptions{PrevalidateFirstRow: true})
+
+			if err == nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() expected error, got none")
+			}
+
+			if err.Error() != tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() error = %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Valid first row", func(t *testing.T) {
+		input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.com,female,192.168.1.2`
+		want := []domainCount{{Domain: "example.com", Count: 2}}
+
+		got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{PrevalidateFirstRow: true})
+		if err != nil {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSkipInvalid(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,first.last@@example.com,male,192.168.1.2
+First,Last,second.last@example.com,female,192.168.1.3
+First,Last,not-an-email,female,192.168.1.4`
+
+	t.Run("Invalid lines stop reading by default", func(t *testing.T) {
+		_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{})
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError", err)
+		}
+
+		if parseErr.Line != 3 || parseErr.Field != FIELD_EMAIL {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error at line %d field %q, want line 3 field %q", parseErr.Line, parseErr.Field, FIELD_EMAIL)
+		}
+	})
+
+	t.Run("Invalid lines are skipped and counted", func(t *testing.T) {
+		var stats ReadStats
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{SkipInvalid: true, Stats: &stats})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if len(got) != 2 {
+			t.Errorf("ReadCustomersFromCSVWithOptions() got %d customers, want 2", len(got))
+		}
+
+		want := ReadStats{Lines: 4, Skipped: 2, InvalidEmails: 2, Bytes: int64(len(input))}
+		if stats != want {
+			t.Errorf("ReadCustomersFromCSVWithOptions() stats = %+v, want %+v", stats, want)
+		}
+
+		if rate := stats.EmailValidityRate(); rate != 0.5 {
+			t.Errorf("ReadStats.EmailValidityRate() = %v, want 0.5", rate)
+		}
+	})
+
+	t.Run("Invalid email counted when name is rejected first", func(t *testing.T) {
+		input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,first.last@@example.com,male,192.168.1.2
+,Last,not-an-email,female,192.168.1.3
+,Last,third.last@example.com,female,192.168.1.4`
+
+		for _, countOnly := range []bool{false, true} {
+			var stats ReadStats
+			_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{SkipInvalid: true, CountOnly: countOnly, Stats: &stats})
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			want := ReadStats{Lines: 4, Skipped: 3, InvalidEmails: 2, Bytes: int64(len(input))}
+			if stats != want {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly %v stats = %+v, want %+v", countOnly, stats, want)
+			}
+
+			if rate := stats.EmailValidityRate(); rate != 0.5 {
+				t.Errorf("ReadStats.EmailValidityRate() with CountOnly %v = %v, want 0.5", countOnly, rate)
+			}
+		}
+	})
+}
+
+func TestReadStatsThroughput(t *testing.T) {
+	input := generateCSV(1000, 10)
+
+	tests := []struct {
+		name string
+		opts ReadOptions
+	}{
+		{name: "Default", opts: ReadOptions{}},
+		{name: "Auto delimiter", opts: ReadOptions{AutoDelimiter: true}},
+		{name: "Count only", opts: ReadOptions{CountOnly: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats ReadStats
+			tt.opts.Stats = &stats
+
+			_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			want := ReadStats{Lines: 1000, Bytes: int64(len(input))}
+			if stats != want {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() stats = %+v, want %+v", stats, want)
+			}
+		})
+	}
+}
+
+func TestMinValidRatio(t *testing.T) {
+	mostlyBad := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,not-an-email,male,192.168.1.2
+First,Last,also-not-an-email,male,192.168.1.3
+First,,third.last@example.com,male,192.168.1.4`
+	mostlyGood := generateCSV(19, 2) + "First,Last,not-an-email,male,192.168.1.2\n"
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		wantErr error
+	}{
+		{
+			name:    "Mostly bad file fails",
+			input:   mostlyBad,
+			opts:    ReadOptions{SkipInvalid: true, MinValidRatio: 0.9},
+			wantErr: ErrLowValidRatio,
+		},
+		{
+			name:    "Ratio reached",
+			input:   mostlyGood,
+			opts:    ReadOptions{SkipInvalid: true, MinValidRatio: 0.9},
+			wantErr: nil,
+		},
+		{
+			name:    "Ratio disabled",
+			input:   mostlyBad,
+			opts:    ReadOptions{SkipInvalid: true},
+			wantErr: nil,
+		},
+		{
+			name:    "Empty file",
+			input:   "",
+			opts:    ReadOptions{SkipInvalid: true, MinValidRatio: 0.9},
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(tt.input), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadStatsEmailValidityRate(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats ReadStats
+		want  float64
+	}{
+		{name: "All valid", stats: ReadStats{Lines: 4}, want: 1},
+		{name: "Half invalid", stats: ReadStats{Lines: 4, Skipped: 3, InvalidEmails: 2}, want: 0.5},
+		{name: "No lines", stats: ReadStats{}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.stats.EmailValidityRate()
+			if got != tt.want {
+				t.Errorf("ReadStats.EmailValidityRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessCSVFileReuseRecord(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+first_name,last_name,email,gender,ip_address
+Second,Last,second.last@example.com,female,192.168.1.2`
+
+	reader := csv.NewReader(strings.NewReader(input))
+	reader.ReuseRecord = true
+
+	var firstNames []string
+	err := ProcessCSVFile(reader, func(csvLine []string, csvLineNumber int) error {
+		firstNames = append(firstNames, csvLine[0])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessCSVFile() unexpected error: %v", err)
+	}
+
+	want := []string{"First", "Second"}
+	if !reflect.DeepEqual(firstNames, want) {
+		t.Errorf("ProcessCSVFile() processed first names %v, want %v", firstNames, want)
+	}
+}
+
+func TestFootersAndComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name: "Footer fails by default",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+Total: 1`,
+			opts:    ReadOptions{},
+			wantErr: true,
+		},
+		{
+			name: "Footer ignored",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+Total: 1`,
+			opts:    ReadOptions{IgnoreFooter: true},
+			want:    []domainCount{{Domain: "example.com", Count: 1}},
+			wantErr: false,
+		},
+		{
+			name: "Malformed line before the end is not a footer",
+			input: `first_name,last_name,email,gender,ip_address
+Total: 1
+First,Last,first.last@example.com,male,192.168.1.1`,
+			opts:    ReadOptions{IgnoreFooter: true},
+			wantErr: true,
+		},
+		{
+			name: "Comments ignored",
+			input: `# exported 2024-01-01
+first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+# end of export`,
+			opts:    ReadOptions{Comment: '#'},
+			want:    []domainCount{{Domain: "example.com", Count: 1}},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(tt.input), tt.opts)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidators(t *testing.T) {
+	errBlockedDomain := errors.New("domain blocked.com is not allowed")
+	rejectBlockedDomain := func(c *customer) error {
+		if c.GetDomain() == "blocked.com" {
+			return errBlockedDomain
+		}
+		return nil
+	}
+
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,first.last@blocked.com,male,192.168.1.2`
+
+	t.Run("Validator error becomes ParseError", func(t *testing.T) {
+		opts := ReadOptions{Validators: []CustomerValidatorFunc{rejectBlockedDomain}}
+		_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), opts)
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError", err)
+		}
+
+		if parseErr.Line != 3 || parseErr.Field != FIELD_CUSTOMER {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error at line %d field %q, want line 3 field %q", parseErr.Line, parseErr.Field, FIELD_CUSTOMER)
+		}
+
+		if !errors.Is(err, errBlockedDomain) {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error = %v, want it to wrap %v", err, errBlockedDomain)
+		}
+	})
+
+	t.Run("Rejected customers are skipped", func(t *testing.T) {
+		opts := ReadOptions{SkipInvalid: true, Validators: []CustomerValidatorFunc{rejectBlockedDomain}}
+		got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), opts)
+		if err != nil {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		want := []domainCount{{Domain: "example.com", Count: 1}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("No customer returned with validator error", func(t *testing.T) {
+		opts := ReadOptions{Validators: []CustomerValidatorFunc{rejectBlockedDomain}}
+		line := []string{"First", "Last", "first.last@blocked.com", "male", "192.168.1.2"}
+		got, err := parseCustomerLineWithOptions(line, 3, opts, defaultColumnIndex)
+		if err == nil {
+			t.Fatalf("parseCustomerLineWithOptions() expected error, got none")
+		}
+
+		if !reflect.DeepEqual(got, customer{}) {
+			t.Errorf("parseCustomerLineWithOptions() got = %v, want empty customer", got)
+		}
+	})
+}
+
+func TestCountDomainsFromCSVReader(t *testing.T) {
+	input := `first_name|last_name|email|gender|ip_address
+First|O"Brien|first.last@example.com|male|192.168.1.1
+First|Last|second.last@example.com|female|192.168.1.2`
+
+	reader := csv.NewReader(strings.NewReader(input))
+	reader.Comma = '|'
+	reader.LazyQuotes = true
+
+	got, err := CountDomainsFromCSVReader(reader)
+	if err != nil {
+		t.Fatalf("CountDomainsFromCSVReader() unexpected error: %v", err)
+	}
+
+	want := []domainCount{{Domain: "example.com", Count: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomainsFromCSVReader() got = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrorRecord(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,first.last@@example.com,male,192.168.1.2
+First,Last,second.last@example.com,female,192.168.1.3`
+
+	_, err := ReadCustomersFromCSV(strings.NewReader(input))
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ReadCustomersFromCSV() error = %v, want ParseError", err)
+	}
+
+	want := []string{"First", "Last", "first.last@@example.com", "male", "192.168.1.2"}
+	if !reflect.DeepEqual(parseErr.Record, want) {
+		t.Errorf("ParseError.Record = %v, want %v", parseErr.Record, want)
+	}
+}
+
+func TestParseErrorRecordBeforeNormalization(t *testing.T) {
+	input := "first_name,last_name,email,gender,ip_address\n" +
+		"First,Last,first.last@example.com,male,192.168.1.1\n" +
+		"Fir\xffst , Last,First Last <first.last@@example.com>,N/A,192.168.1.2\n"
+
+	opts := ReadOptions{
+		TrimFieldSpace:   true,
+		SanitizeUTF8:     true,
+		AllowDisplayName: true,
+		Transform: func(csvLine []string) []string {
+			if csvLine[3] == "N/A" {
+				csvLine[3] = ""
+			}
+			return csvLine
+		},
+	}
+	_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), opts)
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError", err)
+	}
+
+	want := []string{"Fir\xffst ", " Last", "First Last <first.last@@example.com>", "N/A", "192.168.1.2"}
+	if !reflect.DeepEqual(parseErr.Record, want) {
+		t.Errorf("ParseError.Record = %q, want %q", parseErr.Record, want)
+	}
+}
+
+func TestAddCountsFromCSV(t *testing.T) {
+	existing := []domainCount{
+		{Domain: "example1.com", Count: 5},
+		{Domain: "example2.com", Count: 1},
+	}
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example2.com,male,192.168.1.1
+First,Last,second.last@example2.com,female,192.168.1.2
+First,Last,second.last@example3.com,female,192.168.1.3`
+
+	got, err := AddCountsFromCSV(existing, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AddCountsFromCSV() unexpected error: %v", err)
+	}
+
+	want := []domainCount{
+		{Domain: "example1.com", Count: 5},
+		{Domain: "example2.com", Count: 3},
+		{Domain: "example3.com", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddCountsFromCSV() got = %v, want %v", got, want)
+	}
+
+	if existing[1].Count != 1 {
+		t.Errorf("AddCountsFromCSV() modified existing counts: %v", existing)
+	}
+}
+
+func TestMapColumnsByHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name: "Middle name column",
+			input: `first_name,middle_name,last_name,email,gender,ip_address
+First,Middle,Last,first.last@example.com,male,192.168.1.1
+First,,Last,second.last@example.com,female,192.168.1.2`,
+			want: []customer{
+				{FirstName: "First", MiddleName: "Middle", LastName: "Last", Email: "first.last@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1").To4()},
+				{FirstName: "First", LastName: "Last", Email: "second.last@example.com", Gender: female, IPAddress: net.ParseIP("192.168.1.2").To4()},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Reordered and unknown columns",
+			input: `Email,IP_Address,signup_date,Last_Name,First_Name
+first.last@example.com,192.168.1.1,2024-01-01,Last,First`,
+			want: []customer{
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: unknown, IPAddress: net.ParseIP("192.168.1.1").To4()},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing required column",
+			input: `first_name,last_name,gender,ip_address
+First,Last,male,192.168.1.1`,
+			wantErr: true,
+		},
+		{
+			name: "Duplicate column reads the first one",
+			input: `first_name,last_name,email,ip_address,email
+First,Last,first.last@example.com,192.168.1.1,other.last@example.com`,
+			want: []customer{
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: unknown, IPAddress: net.ParseIP("192.168.1.1").To4()},
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(tt.input), ReadOptions{MapColumnsByHeader: true})
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadCustomersFromCSVWithOptions() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCustomersFromCSVWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadersCompatible(t *testing.T) {
+	header := []string{"first_name", "last_name", "email", "gender", "ip_address"}
+
+	tests := []struct {
+		name         string
+		a            []string
+		b            []string
+		want         bool
+		wantAnyOrder bool
+	}{
+		{
+			name:         "Identical headers",
+			a:            header,
+			b:            []string{"first_name", "last_name", "email", "gender", "ip_address"},
+			want:         true,
+			wantAnyOrder: true,
+		},
+		{
+			name:         "Different case and spacing",
+			a:            header,
+			b:            []string{"\ufeffFirst_Name", " last_name", "EMAIL", "gender ", "ip_address"},
+			want:         true,
+			wantAnyOrder: true,
+		},
+		{
+			name:         "Reordered headers",
+			a:            header,
+			b:            []string{"email", "first_name", "last_name", "ip_address", "gender"},
+			want:         false,
+			wantAnyOrder: true,
+		},
+		{
+			name:         "Mismatched column",
+			a:            header,
+			b:            []string{"first_name", "last_name", "email", "gender", "ip"},
+			want:         false,
+			wantAnyOrder: false,
+		},
+		{
+			name:         "Missing column",
+			a:            header,
+			b:            []string{"first_name", "last_name", "email", "gender"},
+			want:         false,
+			wantAnyOrder: false,
+		},
+		{
+			name:         "Repeated column",
+			a:            []string{"email", "email", "gender"},
+			b:            []string{"email", "gender", "gender"},
+			want:         false,
+			wantAnyOrder: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeadersCompatible(tt.a, tt.b); got != tt.want {
+				t.Errorf("HeadersCompatible(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := HeadersCompatibleAnyOrder(tt.a, tt.b); got != tt.wantAnyOrder {
+				t.Errorf("HeadersCompatibleAnyOrder(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.wantAnyOrder)
+			}
+		})
+	}
+}
+
+func TestResolvedColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  ReadOptions
+		want  map[string]string
+	}{
+		{
+			name: "Reordered header",
+			input: "\ufeffEmail,IP_Address,signup_date,Last_Name,First_Name\n" +
+				"first.last@example.com,192.168.1.1,2024-01-01,Last,First",
+			opts: ReadOptions{MapColumnsByHeader: true},
+			want: map[string]string{
+				FIELD_FIRST_NAME: "First_Name",
+				FIELD_LAST_NAME:  "Last_Name",
+				FIELD_EMAIL:      "Email",
+				FIELD_IP_ADDRESS: "IP_Address",
+			},
+		},
+		{
+			name: "Fixed column order",
+			input: `name,surname,mail,sex,ip
+First,Last,first.last@example.com,male,192.168.1.1`,
+			opts: ReadOptions{},
+			want: map[string]string{
+				FIELD_FIRST_NAME: "name",
+				FIELD_LAST_NAME:  "surname",
+				FIELD_EMAIL:      "mail",
+				FIELD_GENDER:     "sex",
+				FIELD_IP_ADDRESS: "ip",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.ResolvedColumns = map[string]string{"stale": "value"}
+
+			_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(tt.input), tt.opts)
+			if err != nil {
+				t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(tt.opts.ResolvedColumns, tt.want) {
+				t.Errorf("ReadCustomersFromCSVWithOptions() resolved columns = %v, want %v", tt.opts.ResolvedColumns, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailPattern(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.org,female,192.168.1.2`
+
+	tests := []struct {
+		name    string
+		opts    ReadOptions
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name:    "Restrictive pattern rejects valid email",
+			opts:    ReadOptions{EmailPattern: regexp.MustCompile(`^[a-z.]+@example\.com$`)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "Restrictive pattern with skipping",
+			opts:    ReadOptions{EmailPattern: regexp.MustCompile(`^[a-z.]+@example\.com$`), SkipInvalid: true},
+			want:    []domainCount{{Domain: "example.com", Count: 1}},
+			wantErr: false,
+		},
+		{
+			name: "Nil pattern falls back to default",
+			opts: ReadOptions{EmailPattern: nil},
+			want: []domainCount{
+				{Domain: "example.com", Count: 1},
+				{Domain: "example.org", Count: 1},
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@gmial,N/A,192.168.1.1
+First,Last,second.last@gmail.com,female,192.168.1.2`
+
+	fixTypos := func(csvLine []string) []string {
+		csvLine[2] = strings.Replace(csvLine[2], "@gmial", "@gmail.com", 1)
+		if csvLine[3] == "N/A" {
+			csvLine[3] = ""
+		}
+		return csvLine
+	}
+
+	t.Run("Bad domain rewritten", func(t *testing.T) {
+		got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{Transform: fixTypos, PrevalidateFirstRow: true})
+		if err != nil {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		want := []domainCount{{Domain: "gmail.com", Count: 2}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Bad domain rejected without transform", func(t *testing.T) {
+		if _, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{}); err == nil {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() expected error, got none")
+		}
+	})
+}
+
+func TestAllowDisplayName(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+John,Doe,John Doe <john.doe@Example.com>,male,192.168.1.1
+Jane,Doe,"""Doe, Jane"" <jane.doe@example.com>",female,192.168.1.2
+Jan,Kowalski,jan@example.org,male,192.168.1.3`
+
+	t.Run("Display names parsed", func(t *testing.T) {
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{AllowDisplayName: true})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		want := []email{"john.doe@Example.com", "jane.doe@example.com", "jan@example.org"}
+		for i, c := range got {
+			if c.Email != want[i] {
+				t.Errorf("ReadCustomersFromCSVWithOptions() email = %q, want %q", c.Email, want[i])
+			}
+		}
+	})
+
+	t.Run("Display names rejected by default", func(t *testing.T) {
+		_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{})
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 2 || parseErr.Field != FIELD_EMAIL {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError at line 2 field %q", err, FIELD_EMAIL)
+		}
+	})
+
+	t.Run("Counted by address domain", func(t *testing.T) {
+		got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{AllowDisplayName: true, CountOnly: true})
+		if err != nil {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		want := []domainCount{{Domain: "example.com", Count: 2}, {Domain: "example.org", Count: 1}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestEmailHasStrictDomain(t *testing.T) {
+	tests := []struct {
+		name  string
+		email email
+		want  bool
+	}{
+		{name: "Two labels", email: "user@example.com", want: true},
+		{name: "Subdomain with hyphen", email: "user@mail-1.example.co.uk", want: true},
+		{name: "Fully qualified", email: "user@example.com.", want: true},
+		{name: "Single label", email: "user@localhost", want: false},
+		{name: "Empty label", email: "user@example..com", want: false},
+		{name: "Leading hyphen", email: "user@-example.com", want: false},
+		{name: "Numeric TLD", email: "user@example.123", want: false},
+		{name: "One letter TLD", email: "user@example.c", want: false},
+		{name: "No domain", email: "user", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.email.hasStrictDomain(); got != tt.want {
+				t.Errorf("email(%q).hasStrictDomain() = %v, want %v", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		opts    ReadOptions
+		wantErr bool
+	}{
+		{
+			name:    "Single label accepted by lenient domain",
+			email:   "user@localhost",
+			opts:    ReadOptions{LenientDomain: true},
+			wantErr: false,
+		},
+		{
+			name:    "Single label rejected in strict mode",
+			email:   "user@localhost",
+			opts:    ReadOptions{LenientDomain: true, StrictDomain: true},
+			wantErr: true,
+		},
+		{
+			name:    "Empty label rejected in strict mode",
+			email:   "user@example..com",
+			opts:    ReadOptions{StrictDomain: true},
+			wantErr: true,
+		},
+		{
+			name:    "Valid domain accepted in strict mode",
+			email:   "user@example.com",
+			opts:    ReadOptions{StrictDomain: true},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "first_name,last_name,email,gender,ip_address\nFirst,Last," + tt.email + ",male,192.168.1.1\n"
+
+			_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUTF8(t *testing.T) {
+	input := "first_name,last_name,email,gender,ip_address\n" +
+		"First,Last,first.last@example.com,male,192.168.1.1\n" +
+		"Fir\xffst,Last,second.last@example.com,female,192.168.1.2\n"
+
+	t.Run("Invalid field rejected", func(t *testing.T) {
+		_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{ValidateUTF8: true})
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError", err)
+		}
+
+		if parseErr.Line != 3 || parseErr.Field != FIELD_FIRST_NAME || !errors.Is(err, ErrInvalidUTF8) {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error = %v, want %v at line 3 field %q", err, ErrInvalidUTF8, FIELD_FIRST_NAME)
+		}
+	})
+
+	t.Run("Invalid field sanitized", func(t *testing.T) {
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{ValidateUTF8: true, SanitizeUTF8: true})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if len(got) != 2 || got[1].FirstName != "Fir\uFFFDst" {
+			t.Errorf("ReadCustomersFromCSVWithOptions() got = %v, want second first name %q", got, "Fir\uFFFDst")
+		}
+	})
+
+	t.Run("Sanitized without ValidateUTF8", func(t *testing.T) {
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{SanitizeUTF8: true})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if len(got) != 2 || got[1].FirstName != "Fir\uFFFDst" {
+			t.Errorf("ReadCustomersFromCSVWithOptions() got = %v, want second first name %q", got, "Fir\uFFFDst")
+		}
+	})
+
+	t.Run("Not validated by default", func(t *testing.T) {
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if len(got) != 2 || got[1].FirstName != "Fir\xffst" {
+			t.Errorf("ReadCustomersFromCSVWithOptions() got = %v, want second first name %q", got, "Fir\xffst")
+		}
+	})
+}
+
+func TestMaxFieldLen(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,` + strings.Repeat("a", 1000) + `@example.com,male,192.168.1.2`
+
+	t.Run("Over-length field rejected", func(t *testing.T) {
+		_, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{MaxFieldLen: 100})
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want ParseError", err)
+		}
+
+		if parseErr.Line != 3 || parseErr.Field != FIELD_EMAIL {
+			t.Errorf("ReadCustomersFromCSVWithOptions() error at line %d field %q, want line 3 field %q", parseErr.Line, parseErr.Field, FIELD_EMAIL)
+		}
+	})
+
+	t.Run("Unlimited by default", func(t *testing.T) {
+		got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{})
+		if err != nil {
+			t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+		}
+
+		if len(got) != 2 {
+			t.Errorf("ReadCustomersFromCSVWithOptions() got %d customers, want 2", len(got))
+		}
+	})
+}
+
+func TestEmptyInput(t *testing.T) {
+	customers, err := ReadCustomersFromCSV(strings.NewReader(""))
+	if err != nil {
+		t.Errorf("ReadCustomersFromCSV() unexpected error: %v", err)
+	}
+	if customers == nil || len(customers) != 0 {
+		t.Errorf("ReadCustomersFromCSV() got = %#v, want empty non-nil slice", customers)
+	}
+
+	counts, err := ReadAndCountDomainsFromCSV(strings.NewReader(""))
+	if err != nil {
+		t.Errorf("ReadAndCountDomainsFromCSV() unexpected error: %v", err)
+	}
+	if counts == nil || len(counts) != 0 {
+		t.Errorf("ReadAndCountDomainsFromCSV() got = %#v, want empty non-nil slice", counts)
+	}
+}
+
+func TestHeaderOnlyInput(t *testing.T) {
+	input := "first_name,last_name,email,gender,ip_address\n"
+
+	customers, err := ReadCustomersFromCSV(strings.NewReader(input))
+	if err != nil {
+		t.Errorf("ReadCustomersFromCSV() unexpected error: %v", err)
+	}
+	if customers == nil || len(customers) != 0 {
+		t.Errorf("ReadCustomersFromCSV() got = %#v, want empty non-nil slice", customers)
+	}
+
+	counts, err := ReadAndCountDomainsFromCSV(strings.NewReader(input))
+	if err != nil {
+		t.Errorf("ReadAndCountDomainsFromCSV() unexpected error: %v", err)
+	}
+	if counts == nil || len(counts) != 0 {
+		t.Errorf("ReadAndCountDomainsFromCSV() got = %#v, want empty non-nil slice", counts)
+	}
+}
+
+func TestCustomersSeq(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+Second,Last,second.last@example.com,female,192.168.1.2
+Third,Last,bademail,female,192.168.1.3
+Fourth,Last,fourth.last@example.com,female,192.168.1.4`
+
+	t.Run("Stops at first error", func(t *testing.T) {
+		var firstNames []string
+		var errs []error
+		for c, err := range CustomersSeq(strings.NewReader(input)) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			firstNames = append(firstNames, c.FirstName)
+		}
+
+		if want := []string{"First", "Second"}; !reflect.DeepEqual(firstNames, want) {
+			t.Errorf("CustomersSeq() yielded %v, want %v", firstNames, want)
+		}
+
+		var parseErr *ParseError
+		if len(errs) != 1 || !errors.As(errs[0], &parseErr) || parseErr.Line != 4 {
+			t.Errorf("CustomersSeq() yielded errors %v, want single ParseError at line 4", errs)
+		}
+	})
+
+	t.Run("Break stops reading", func(t *testing.T) {
+		count := 0
+		for _, err := range CustomersSeq(strings.NewReader(input)) {
+			if err != nil {
+				t.Fatalf("CustomersSeq() unexpected error: %v", err)
+			}
+			count++
+			break
+		}
+
+		if count != 1 {
+			t.Errorf("CustomersSeq() yielded %d customers after break, want 1", count)
+		}
+	})
+}
+
+func TestStripWWW(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,user@www.example.com,male,192.168.1.1
+First,Last,user@example.com,female,192.168.1.2
+First,Last,user@wwwexample.com,female,192.168.1.3`
+
+	tests := []struct {
+		name string
+		opts ReadOptions
+		want []domainCount
+	}{
+		{
+			name: "Disabled by default",
+			opts: ReadOptions{},
+			want: []domainCount{
+				{Domain: "example.com", Count: 1},
+				{Domain: "www.example.com", Count: 1},
+				{Domain: "wwwexample.com", Count: 1},
+			},
+		},
+		{
+			name: "Enabled",
+			opts: ReadOptions{StripWWW: true},
 			want: []domainCount{
 				{Domain: "example.com", Count: 2},
+				{Domain: "wwwexample.com", Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "Comma delimited",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1`,
+			opts:    ReadOptions{},
+			want:    []string{"first_name", "last_name", "email", "gender", "ip_address"},
+			wantErr: false,
+		},
+		{
+			name:    "Auto delimiter",
+			input:   "email;name\nfirst.last@example.com;First",
+			opts:    ReadOptions{AutoDelimiter: true},
+			want:    []string{"email", "name"},
+			wantErr: false,
+		},
+		{
+			name:    "Empty input",
+			input:   "",
+			opts:    ReadOptions{},
+			want:    []string{},
+			wantErr: false,
+		},
+		{
+			name:    "Malformed header",
+			input:   `"email,name`,
+			opts:    ReadOptions{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadHeader(strings.NewReader(tt.input), tt.opts)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("ReadHeader() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("ReadHeader() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadHeader() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountDomainsFromCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		emailColumn int
+		want        []domainCount
+		wantErr     bool
+	}{
+		{
+			name: "Email in first column",
+			input: `email,name
+first.last@example1.com,
+second.last@example1.com,Second
+third.last@example2.com,Third,extra`,
+			emailColumn: 0,
+			want: []domainCount{
+				{Domain: "example1.com", Count: 2},
+				{Domain: "example2.com", Count: 1},
 			},
 			wantErr: false,
 		},
 		{
-			name: "Valid CSV data - multiple domains",
-			input: `first_name,last_name,email,gender,ip_address
-First,Last,first.last@example1.com,male,192.168.1.1
-First,Last,second.last@example2.com,female,192.168.1.2
-First,Last,second.last@example1.com,female,192.168.1.2`,
+			name: "Invalid email",
+			input: `email
+bademail`,
+			emailColumn: 0,
+			wantErr:     true,
+		},
+		{
+			name: "Column out of range",
+			input: `email
+first.last@example.com`,
+			emailColumn: 3,
+			wantErr:     true,
+		},
+		{
+			name: "Negative column",
+			input: `email
+first.last@example.com`,
+			emailColumn: -1,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CountDomainsFromCSV(strings.NewReader(tt.input), tt.emailColumn)
+
+			if err != nil && !tt.wantErr {
+				t.Errorf("CountDomainsFromCSV() unexpected error: %v", err)
+				return
+			}
+
+			if err == nil && tt.wantErr {
+				t.Errorf("CountDomainsFromCSV() expected error, got none")
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountDomainsFromCSV() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLenientDomain(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,user@localhost,male,192.168.1.1
+First,Last,user@example.com,female,192.168.1.2`
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []domainCount
+		wantErr bool
+	}{
+		{
+			name:    "Strict by default",
+			input:   input,
+			opts:    ReadOptions{},
+			wantErr: true,
+		},
+		{
+			name:  "Lenient",
+			input: input,
+			opts:  ReadOptions{LenientDomain: true},
 			want: []domainCount{
-				{Domain: "example1.com", Count: 2},
-				{Domain: "example2.com", Count: 1},
+				{Domain: "example.com", Count: 1},
+				{Domain: "localhost", Count: 1},
 			},
 			wantErr: false,
 		},
 		{
-			name: "Invalid CSV data - bad email",
+			name: "Lenient still requires a domain",
 			input: `first_name,last_name,email,gender,ip_address
-First,Last,user1@@example.com,male,192.168.1.1`,
+First,Last,user@,male,192.168.1.1`,
+			opts:    ReadOptions{LenientDomain: true},
 			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := strings.NewReader(tt.input)
-			got, err := ReadAndCountDomainsFromCSV(r)
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(tt.input), tt.opts)
 
 			if err != nil && !tt.wantErr {
-				t.Errorf("ReadAndCountDomainsFromCSV() unexpected error: %v", err)
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
 				return
 			}
 
 			if err == nil && tt.wantErr {
-				t.Errorf("ReadAndCountDomainsFromCSV() expected error, got none")
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() expected error, got none")
 				return
 			}
 
 			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ReadAndCountDomainsFromCSV() got = %v, want %v", got, tt.want)
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomerIPString(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipAddress string
+		want      string
+		wantLen   int
+	}{
+		{name: "IPv4", ipAddress: "192.168.1.1", want: "192.168.1.1", wantLen: net.IPv4len},
+		{name: "IPv4-mapped IPv6", ipAddress: "::ffff:192.168.1.2", want: "192.168.1.2", wantLen: net.IPv4len},
+		{name: "IPv6", ipAddress: "2001:DB8::1", want: "2001:db8::1", wantLen: net.IPv6len},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := []string{"First", "Last", "first.last@example.com", "male", tt.ipAddress}
+			c, err := parseCustomerLine(line, 2)
+			if err != nil {
+				t.Fatalf("parseCustomerLine() unexpected error: %v", err)
+			}
+
+			if len(c.IPAddress) != tt.wantLen {
+				t.Errorf("parseCustomerLine() IP address length = %d, want %d", len(c.IPAddress), tt.wantLen)
+			}
+
+			if got := c.IPString(); got != tt.want {
+				t.Errorf("customer.IPString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (customer{}).IPString(); got != "" {
+		t.Errorf("customer.IPString() without IP address = %q, want empty string", got)
+	}
+}
+
+func TestHostnameInIPColumn(t *testing.T) {
+	tests := []struct {
+		name         string
+		ipAddress    string
+		wantHostname bool
+	}{
+		{name: "Hostname", ipAddress: "mail.example.com", wantHostname: true},
+		{name: "Malformed IPv4", ipAddress: "192.168.1", wantHostname: false},
+		{name: "Malformed IPv6 with hex letters", ipAddress: "fe80::zz", wantHostname: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := []string{"First", "Last", "first.last@example.com", "male", tt.ipAddress}
+			_, err := parseCustomerLine(line, 2)
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Field != FIELD_IP_ADDRESS {
+				t.Fatalf("parseCustomerLine() error = %v, want ParseError for %q", err, FIELD_IP_ADDRESS)
+			}
+
+			if got := errors.Is(err, ErrHostnameNotIP); got != tt.wantHostname {
+				t.Errorf("parseCustomerLine() error = %v, hostname hint %v, want %v", err, got, tt.wantHostname)
+			}
+		})
+	}
+
+	line := []string{"First", "Last", "first.last@example.com", "male", "mail.example.com"}
+	_, err := parseCustomerLine(line, 2)
+	want := "invalid ip address at line 2: expected IP address, got hostname-like value: mail.example.com"
+	if err == nil || err.Error() != want {
+		t.Errorf("parseCustomerLine() error = %v, want %q", err, want)
+	}
+}
+
+func TestEstimatedRows(t *testing.T) {
+	input := generateCSV(100, 10)
+
+	wantCustomers, err := ReadCustomersFromCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadCustomersFromCSV() unexpected error: %v", err)
+	}
+	wantCounts, err := ReadAndCountDomainsFromCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadAndCountDomainsFromCSV() unexpected error: %v", err)
+	}
+
+	for _, estimate := range []int{-1, 10, 100, 1000} {
+		t.Run(fmt.Sprintf("EstimatedRows=%d", estimate), func(t *testing.T) {
+			opts := ReadOptions{EstimatedRows: estimate}
+
+			customers, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(customers, wantCustomers) {
+				t.Errorf("ReadCustomersFromCSVWithOptions() = %v, want %v", customers, wantCustomers)
+			}
+
+			counts, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(counts, wantCounts) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", counts, wantCounts)
+			}
+		})
+	}
+}
+
+func TestDomainFilter(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first@example1.com,male,192.168.1.1
+First,Last,second@example2.com,female,192.168.1.2
+First,Last,third@example1.com,female,192.168.1.3
+First,Last,fourth@example1.com,male,192.168.1.4
+First,Last,fifth@example2.com,male,192.168.1.5`
+
+	for _, countOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("CountOnly=%v", countOnly), func(t *testing.T) {
+			// the denylist is swapped after the second line, like a reload in a long-running service
+			var denylist atomic.Pointer[map[string]bool]
+			denylist.Store(&map[string]bool{})
+			seen := 0
+
+			opts := ReadOptions{
+				CountOnly: countOnly,
+				DomainFilter: func(domain string) bool {
+					seen++
+					if seen == 3 {
+						denylist.Store(&map[string]bool{"example1.com": true})
+					}
+					return !(*denylist.Load())[domain]
+				},
+			}
+
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), opts)
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			want := []domainCount{{Domain: "example2.com", Count: 2}, {Domain: "example1.com", Count: 1}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestExcludeRoleAccounts(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,noreply@example.com,female,192.168.1.2
+First,Last,Postmaster+bounces@example.org,female,192.168.1.3
+First,Last,admin@example.org,male,192.168.1.4
+First,Last,administrator.jones@example.org,male,192.168.1.5`
+
+	tests := []struct {
+		name string
+		opts ReadOptions
+		want []domainCount
+	}{
+		{
+			name: "Disabled by default",
+			opts: ReadOptions{},
+			want: []domainCount{{Domain: "example.org", Count: 3}, {Domain: "example.com", Count: 2}},
+		},
+		{
+			name: "Default role accounts",
+			opts: ReadOptions{ExcludeRoleAccounts: true},
+			want: []domainCount{{Domain: "example.com", Count: 1}, {Domain: "example.org", Count: 1}},
+		},
+		{
+			name: "Default role accounts count only",
+			opts: ReadOptions{ExcludeRoleAccounts: true, CountOnly: true},
+			want: []domainCount{{Domain: "example.com", Count: 1}, {Domain: "example.org", Count: 1}},
+		},
+		{
+			name: "Custom role accounts",
+			opts: ReadOptions{ExcludeRoleAccounts: true, RoleAccounts: map[string]bool{"noreply": true}},
+			want: []domainCount{{Domain: "example.org", Count: 3}, {Domain: "example.com", Count: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomerReaderReadBatch(t *testing.T) {
+	input := generateCSV(10, 3)
+	want, err := ReadCustomersFromCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadCustomersFromCSV() unexpected error: %v", err)
+	}
+
+	cr := NewCustomerReader(strings.NewReader(input), ReadOptions{})
+	defer cr.Close()
+
+	var got []customer
+	var sizes []int
+	for {
+		batch, err := cr.ReadBatch(4)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("CustomerReader.ReadBatch() unexpected error: %v", err)
+		}
+		sizes = append(sizes, len(batch))
+		got = append(got, batch...)
+	}
+
+	if wantSizes := []int{4, 4, 2}; !reflect.DeepEqual(sizes, wantSizes) {
+		t.Errorf("CustomerReader.ReadBatch() batch sizes = %v, want %v", sizes, wantSizes)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomerReader.ReadBatch() = %v, want %v", got, want)
+	}
+
+	if _, err := cr.ReadBatch(4); !errors.Is(err, io.EOF) {
+		t.Errorf("CustomerReader.ReadBatch() after end error = %v, want io.EOF", err)
+	}
+}
+
+func TestCustomerReaderReadBatchError(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+Second,Last,second.last@example.com,female,192.168.1.2
+Third,Last,bademail,female,192.168.1.3
+Fourth,Last,fourth.last@example.com,female,192.168.1.4`
+
+	cr := NewCustomerReader(strings.NewReader(input), ReadOptions{})
+	defer cr.Close()
+
+	batch, err := cr.ReadBatch(10)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 4 {
+		t.Fatalf("CustomerReader.ReadBatch() error = %v, want ParseError at line 4", err)
+	}
+	if len(batch) != 2 {
+		t.Errorf("CustomerReader.ReadBatch() returned %d customers before error, want 2", len(batch))
+	}
+
+	if _, laterErr := cr.ReadBatch(10); laterErr != err {
+		t.Errorf("CustomerReader.ReadBatch() after error = %v, want %v", laterErr, err)
+	}
+}
+
+func TestCustomerReaderClose(t *testing.T) {
+	cr := NewCustomerReader(strings.NewReader(generateCSV(10, 3)), ReadOptions{})
+
+	if _, err := cr.ReadBatch(2); err != nil {
+		t.Fatalf("CustomerReader.ReadBatch() unexpected error: %v", err)
+	}
+	if err := cr.Close(); err != nil {
+		t.Fatalf("CustomerReader.Close() unexpected error: %v", err)
+	}
+	if _, err := cr.ReadBatch(2); !errors.Is(err, io.EOF) {
+		t.Errorf("CustomerReader.ReadBatch() after Close() error = %v, want io.EOF", err)
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+ John,"  Doe  ",john.doe@example.com,male,192.168.1.1`
+
+	tests := []struct {
+		name          string
+		opts          ReadOptions
+		wantFirstName string
+		wantLastName  string
+	}{
+		{
+			name:          "Disabled by default",
+			opts:          ReadOptions{},
+			wantFirstName: " John",
+			wantLastName:  "  Doe  ",
+		},
+		{
+			name:          "Leading space outside quotes",
+			opts:          ReadOptions{TrimLeadingSpace: true},
+			wantFirstName: "John",
+			wantLastName:  "  Doe  ",
+		},
+		{
+			name:          "Field space inside quotes",
+			opts:          ReadOptions{TrimFieldSpace: true},
+			wantFirstName: "John",
+			wantLastName:  "Doe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), tt.opts)
+			if err != nil {
+				t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+			}
+
+			if got[0].FirstName != tt.wantFirstName || got[0].LastName != tt.wantLastName {
+				t.Errorf("ReadCustomersFromCSVWithOptions() names = %q %q, want %q %q",
+					got[0].FirstName, got[0].LastName, tt.wantFirstName, tt.wantLastName)
+			}
+		})
+	}
+}
+
+func TestTrimLeadingSpaceBeforeQuotes(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+John, "Doe",john.doe@example.com,male,192.168.1.1`
+
+	if _, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{TrimFieldSpace: true}); err == nil {
+		t.Errorf("ReadCustomersFromCSVWithOptions() with TrimFieldSpace expected error for space before quote, got none")
+	}
+
+	got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{TrimLeadingSpace: true})
+	if err != nil {
+		t.Fatalf("ReadCustomersFromCSVWithOptions() unexpected error: %v", err)
+	}
+	if got[0].LastName != "Doe" {
+		t.Errorf("ReadCustomersFromCSVWithOptions() last name = %q, want %q", got[0].LastName, "Doe")
+	}
+}
+
+func TestRecordSeparator(t *testing.T) {
+	lines := []string{
+		"first_name,last_name,email,gender,ip_address",
+		"First,Last,first.last@example1.com,male,192.168.1.1",
+		`First,"Last, Jr.",second.last@example2.com,female,192.168.1.2`,
+		"First,Last,third.last@example1.com,female,192.168.1.3",
+	}
+	want := []domainCount{{Domain: "example1.com", Count: 2}, {Domain: "example2.com", Count: 1}}
+
+	tests := []struct {
+		name      string
+		separator string
+		oneByte   bool
+	}{
+		{name: "Carriage return only", separator: "\r"},
+		{name: "Carriage return only read byte by byte", separator: "\r", oneByte: true},
+		{name: "Record separator control character", separator: "\x1e"},
+		{name: "Multi-byte separator read byte by byte", separator: "~~", oneByte: true},
+		{name: "Overlapping multi-byte separator read byte by byte", separator: "~~~", oneByte: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(strings.Join(lines, tt.separator))
+			if tt.oneByte {
+				r = iotest.OneByteReader(r)
+			}
+
+			got, err := ReadAndCountDomainsFromCSVWithOptions(r, ReadOptions{RecordSeparator: tt.separator})
+			if err != nil {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Carriage return only without option", func(t *testing.T) {
+		// the whole file is read as a single line, taken for the header
+		input := strings.Join(lines, "\r")
+		got, err := ReadAndCountDomainsFromCSV(strings.NewReader(input))
+		if err == nil && len(got) != 0 {
+			t.Errorf("ReadAndCountDomainsFromCSV() = %v, want no counts or error", got)
+		}
+	})
+}
+
+func TestRecordSeparatorReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		separator string
+		want      string
+	}{
+		{name: "Single byte", input: "a\rb\rc", separator: "\r", want: "a\nb\nc"},
+		{name: "Separator at end", input: "a||b||", separator: "||", want: "a\nb\n"},
+		{name: "Odd repetition", input: "a|||b", separator: "||", want: "a\n|b"},
+		{name: "Partial separator at end", input: "a||b|", separator: "||", want: "a\nb|"},
+		{name: "No separator", input: "abc", separator: "||", want: "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(newRecordSeparatorReader(iotest.OneByteReader(strings.NewReader(tt.input)), tt.separator))
+			if err != nil {
+				t.Fatalf("recordSeparatorReader unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("recordSeparatorReader = %q, want %q", got, tt.want)
+			}
+			if want := strings.ReplaceAll(tt.input, tt.separator, "\n"); string(got) != want {
+				t.Errorf("recordSeparatorReader = %q, want strings.ReplaceAll() result %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadAndCountDomainsWithOriginal(t *testing.T) {
+	input := `first_name,last_name,email,gender,ip_address
+First,Last,first.last@Example.COM,male,192.168.1.1
+First,Last,second.last@example.com,female,192.168.1.2
+First,Last,third.last@www.Foo.org.,female,192.168.1.3`
+
+	got, err := ReadAndCountDomainsWithOriginal(strings.NewReader(input), ReadOptions{StripWWW: true})
+	if err != nil {
+		t.Fatalf("ReadAndCountDomainsWithOriginal() unexpected error: %v", err)
+	}
+
+	want := []domainCountWithOriginal{
+		{domainCount: domainCount{Domain: "example.com", Count: 2}, Original: "Example.COM"},
+		{domainCount: domainCount{Domain: "foo.org", Count: 1}, Original: "www.Foo.org."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAndCountDomainsWithOriginal() = %v, want %v", got, want)
+	}
+
+	counts, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{StripWWW: true})
+	if err != nil {
+		t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() unexpected error: %v", err)
+	}
+	for i, dc := range counts {
+		if got[i].domainCount != dc {
+			t.Errorf("ReadAndCountDomainsWithOriginal() count %d = %v, want %v", i, got[i].domainCount, dc)
+		}
+	}
+
+	encoded, err := json.Marshal(got[0])
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	if want := `{"domain":"example.com","count":2,"original":"Example.COM"}`; string(encoded) != want {
+		t.Errorf("json.Marshal() = %s, want %s", encoded, want)
+	}
+}
+
+func TestSkipInvalidStructuralErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		opts        ReadOptions
+		wantErr     error
+		wantSkipped int
+	}{
+		{
+			name: "Invalid data skipped",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,not-an-email,male,192.168.1.2
+First,Last,third.last@example.com,male,192.168.1.3`,
+			opts:        ReadOptions{SkipInvalid: true},
+			wantErr:     nil,
+			wantSkipped: 1,
+		},
+		{
+			name: "Wrong field count aborts",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.com,male
+First,Last,third.last@example.com,male,192.168.1.3`,
+			opts:    ReadOptions{SkipInvalid: true},
+			wantErr: csv.ErrFieldCount,
+		},
+		{
+			name: "Missing mapped fields abort",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example.com,male,192.168.1.1
+First,Last,second.last@example.com,male,192.168.1.2`,
+			opts: ReadOptions{SkipInvalid: true, Transform: func(csvLine []string) []string {
+				if strings.HasPrefix(csvLine[2], "second") {
+					return csvLine[:3]
+				}
+				return csvLine
+			}},
+			wantErr: ErrMissingFields,
+		},
+		{
+			name:    "Read error aborts",
+			input:   "",
+			opts:    ReadOptions{SkipInvalid: true},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats ReadStats
+			tt.opts.Stats = &stats
+
+			var r io.Reader = strings.NewReader(tt.input)
+			if tt.wantErr == io.ErrUnexpectedEOF {
+				r = io.MultiReader(strings.NewReader(generateCSV(10, 2)), iotest.ErrReader(io.ErrUnexpectedEOF))
+			}
+
+			_, err := ReadCustomersFromCSVWithOptions(r, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadCustomersFromCSVWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && stats.Skipped != tt.wantSkipped {
+				t.Errorf("ReadCustomersFromCSVWithOptions() skipped %d lines, want %d", stats.Skipped, tt.wantSkipped)
 			}
 		})
 	}
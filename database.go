@@ -0,0 +1,55 @@
+package customerimporter
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Function "CountDomainsFromRows" counts domains of emails read from the first column of database query results,
+// e.g. "SELECT email FROM customers". See "CountDomainsFromRowsColumn" for other columns.
+func CountDomainsFromRows(rows *sql.Rows) ([]domainCount, error) {
+	return CountDomainsFromRowsColumn(rows, 0)
+}
+
+// Function "CountDomainsFromRowsColumn" counts domains of emails found in given column (counted from 0) of database query results.
+// Other columns are ignored. Rows are numbered from 1 in returned "ParseError", NULL emails are reported as invalid.
+// Rows are read until exhausted, closing them is left to the caller.
+func CountDomainsFromRowsColumn(rows *sql.Rows, emailColumn int) ([]domainCount, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if emailColumn < 0 || emailColumn >= len(columns) {
+		return nil, fmt.Errorf("invalid email column %d, query returned %d columns", emailColumn, len(columns))
+	}
+
+	var value sql.NullString
+	dest := make([]any, len(columns))
+	for i := range dest {
+		dest[i] = new(sql.RawBytes)
+	}
+	dest[emailColumn] = &value
+
+	domainCounter := NewDomainCounter()
+	rowNumber := 0
+
+	for rows.Next() {
+		rowNumber++
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan row %d: %w", rowNumber, err)
+		}
+
+		email := email(value.String)
+		if !value.Valid || !email.isValid() {
+			return nil, &ParseError{Line: rowNumber, Field: FIELD_EMAIL, Value: string(email)}
+		}
+
+		domainCounter.Add(email.extractDomain())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
@@ -0,0 +1,159 @@
+package customerimporter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// Type "fakeConnector" is an in-memory "database/sql" driver answering every query with the same result set.
+type fakeConnector struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{c}, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ fakeConnector }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{columns: c.columns, values: c.values}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// Function "queryFakeRows" returns query results holding given columns and values.
+func queryFakeRows(t *testing.T, columns []string, values [][]driver.Value) *sql.Rows {
+	t.Helper()
+
+	db := sql.OpenDB(fakeConnector{columns: columns, values: values})
+	t.Cleanup(func() { db.Close() })
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatalf("failed to query fake database: %v", err)
+	}
+	t.Cleanup(func() { rows.Close() })
+
+	return rows
+}
+
+func TestCountDomainsFromRows(t *testing.T) {
+	rows := queryFakeRows(t, []string{"email"}, [][]driver.Value{
+		{"first.last@example.com"},
+		{[]byte("second.last@Example.com")},
+		{"third.last@example.org"},
+	})
+
+	got, err := CountDomainsFromRows(rows)
+	if err != nil {
+		t.Fatalf("CountDomainsFromRows() error = %v", err)
+	}
+
+	want := []domainCount{
+		{Domain: "example.com", Count: 2},
+		{Domain: "example.org", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountDomainsFromRows() = %v, want %v", got, want)
+	}
+}
+
+func TestCountDomainsFromRowsColumn(t *testing.T) {
+	columns := []string{"id", "first_name", "email"}
+
+	tests := []struct {
+		name        string
+		values      [][]driver.Value
+		emailColumn int
+		want        []domainCount
+		wantErr     bool
+	}{
+		{
+			name: "Email in third column",
+			values: [][]driver.Value{
+				{int64(1), "First", "first.last@example.com"},
+				{int64(2), nil, "second.last@example.com"},
+			},
+			emailColumn: 2,
+			want:        []domainCount{{Domain: "example.com", Count: 2}},
+			wantErr:     false,
+		},
+		{
+			name:        "No rows",
+			values:      nil,
+			emailColumn: 2,
+			want:        []domainCount{},
+			wantErr:     false,
+		},
+		{
+			name: "Invalid email",
+			values: [][]driver.Value{
+				{int64(1), "First", "not-an-email"},
+			},
+			emailColumn: 2,
+			want:        nil,
+			wantErr:     true,
+		},
+		{
+			name: "NULL email",
+			values: [][]driver.Value{
+				{int64(1), "First", nil},
+			},
+			emailColumn: 2,
+			want:        nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Column out of range",
+			values:      nil,
+			emailColumn: 3,
+			want:        nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Negative column",
+			values:      nil,
+			emailColumn: -1,
+			want:        nil,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := queryFakeRows(t, columns, tt.values)
+
+			got, err := CountDomainsFromRowsColumn(rows, tt.emailColumn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CountDomainsFromRowsColumn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountDomainsFromRowsColumn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
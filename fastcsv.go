@@ -0,0 +1,158 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Interface "recordReader" is for readers returning CSV file line by line, like "csv.Reader".
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// Function "newRecordReader" returns a reader of CSV lines according to "ReadOptions", "fastCSVReader" when "ReadOptions.FastCSV"
+// applies and "csv.Reader" otherwise.
+func newRecordReader(r io.Reader, opts ReadOptions) (recordReader, error) {
+	if !opts.FastCSV || !opts.CountOnly || opts.TrimLeadingSpace {
+		return newCSVReader(r, opts)
+	}
+
+	r, delimiter, err := prepareCSVInput(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if delimiter == 0 {
+		delimiter = ','
+	}
+
+	if !isFastCSVDelimiter(delimiter) || (opts.Comment != 0 && (!isFastCSVDelimiter(opts.Comment) || opts.Comment == delimiter)) {
+		// let "encoding/csv" handle, or reject, characters the splitter does not
+		opts.AutoDelimiter, opts.RecordSeparator, opts.Delimiter = false, "", delimiter
+		return newCSVReader(r, opts)
+	}
+
+	return &fastCSVReader{
+		reader:    bufio.NewReader(r),
+		delimiter: byte(delimiter),
+		comment:   byte(opts.Comment),
+	}, nil
+}
+
+// Function "isFastCSVDelimiter" checks whether "fastCSVReader" can split lines on given character: an ASCII one
+// that is not a quote or line ending.
+func isFastCSVDelimiter(delimiter rune) bool {
+	return delimiter < utf8.RuneSelf && delimiter != '"' && delimiter != '\r' && delimiter != '\n'
+}
+
+// Type "fastCSVReader" reads CSV lines without quoted fields by splitting them on the delimiter, see "ReadOptions.FastCSV".
+// Lines are returned exactly like "csv.Reader" with "ReuseRecord" would return them. From the first line with a quote on,
+// reading is handed over to "csv.Reader".
+type fastCSVReader struct {
+	reader    *bufio.Reader
+	delimiter byte
+	comment   byte
+	// fieldsPerRecord is the number of fields of the first line, as "csv.Reader.FieldsPerRecord" after it is read
+	fieldsPerRecord int
+	lineNumber      int
+	record          []string
+	line            []byte
+	fallback        *csv.Reader
+	// fallbackLineOffset is the number of lines read before "fallback" took over, added to line numbers of its errors
+	fallbackLineOffset int
+}
+
+func (fr *fastCSVReader) Read() ([]string, error) {
+	if fr.fallback != nil {
+		return fr.readFallback()
+	}
+
+	for {
+		line, err := fr.readLine()
+		if err != nil {
+			return nil, err
+		}
+		fr.lineNumber++
+
+		if bytes.IndexByte(line, '"') >= 0 {
+			return fr.handOver(line)
+		}
+
+		// "csv.Reader" drops line endings, a carriage return before the final line feed or at the end of input included
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+
+		if len(line) == 0 || (fr.comment != 0 && line[0] == fr.comment) {
+			continue
+		}
+
+		// a single string per line, as "csv.Reader" does, so fields stay valid after the next line is read
+		fields := string(line)
+		fr.record = fr.record[:0]
+		for {
+			field, rest, found := strings.Cut(fields, string(fr.delimiter))
+			fr.record = append(fr.record, field)
+			if !found {
+				break
+			}
+			fields = rest
+		}
+
+		if fr.fieldsPerRecord == 0 {
+			fr.fieldsPerRecord = len(fr.record)
+		} else if len(fr.record) != fr.fieldsPerRecord {
+			return fr.record, &csv.ParseError{StartLine: fr.lineNumber, Line: fr.lineNumber, Column: 1, Err: csv.ErrFieldCount}
+		}
+
+		return fr.record, nil
+	}
+}
+
+// Method "readLine" returns the next physical line with its line ending, or "io.EOF" when the input ends.
+// The line is only valid until the next call.
+func (fr *fastCSVReader) readLine() ([]byte, error) {
+	line, err := fr.reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		fr.line = append(fr.line[:0], line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			line, err = fr.reader.ReadSlice('\n')
+			fr.line = append(fr.line, line...)
+		}
+		line = fr.line
+	}
+
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+	}
+	return line, err
+}
+
+// Method "handOver" switches reading to "csv.Reader", starting with "line" as it was read, and returns its first line.
+func (fr *fastCSVReader) handOver(line []byte) ([]string, error) {
+	fr.fallback = csv.NewReader(io.MultiReader(bytes.NewReader(bytes.Clone(line)), fr.reader))
+	fr.fallback.ReuseRecord = true
+	fr.fallback.Comma = rune(fr.delimiter)
+	fr.fallback.Comment = rune(fr.comment)
+	fr.fallback.FieldsPerRecord = fr.fieldsPerRecord
+	fr.fallbackLineOffset = fr.lineNumber - 1
+
+	return fr.readFallback()
+}
+
+// Method "readFallback" reads a line with "csv.Reader" after "handOver", reporting lines of errors counted from the start
+// of input, as if "csv.Reader" read all of it.
+func (fr *fastCSVReader) readFallback() ([]string, error) {
+	record, err := fr.fallback.Read()
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		shifted := *parseErr
+		shifted.StartLine += fr.fallbackLineOffset
+		shifted.Line += fr.fallbackLineOffset
+		err = &shifted
+	}
+
+	return record, err
+}
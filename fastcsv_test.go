@@ -0,0 +1,187 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Function "readAllRecords" reads all lines of "reader", copying them as the reader may reuse the slice.
+func readAllRecords(reader recordReader) ([][]string, error) {
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, append([]string(nil), record...))
+	}
+}
+
+func TestFastCSVReader(t *testing.T) {
+	longField := strings.Repeat("x", 10000)
+
+	tests := []struct {
+		name  string
+		input string
+		opts  ReadOptions
+	}{
+		{name: "Simple lines", input: "a,b,c\n1,2,3\n4,5,6\n"},
+		{name: "No final line feed", input: "a,b,c\n1,2,3"},
+		{name: "CRLF line endings", input: "a,b,c\r\n1,2,3\r\n4,5,6\r"},
+		{name: "Blank lines", input: "a,b,c\n\n1,2,3\r\n\r\n4,5,6\n\n"},
+		{name: "Empty fields", input: "a,b,c\n,,\n1,,3\n"},
+		{name: "Carriage return inside field", input: "a,b,c\n1,2\r2,3\n"},
+		{name: "Quoted line hands over", input: "a,b,c\n1,2,3\n\"4\",\"5,5\",\"6\n6\"\n7,8,9\n"},
+		{name: "Quoted header", input: "\"a\",b,c\n1,2,3\n"},
+		{name: "Comments", input: "a,b,c\n#1,2,3\n4,5,6\n# \"quoted\" comment\n7,8,9\n", opts: ReadOptions{Comment: '#'}},
+		{name: "Semicolon delimiter", input: "a;b;c\n1;2,2;3\n", opts: ReadOptions{Delimiter: ';'}},
+		{name: "Long line", input: "a,b,c\n1," + longField + ",3\n4,5,6\n"},
+		{name: "Field count mismatch", input: "a,b,c\n1,2,3\n4,5\n7,8,9\n"},
+		{name: "Bare quote", input: "a,b,c\n1,2\"2,3\n"},
+		{name: "Bare quote after quoted line", input: "a,b,c\n\n1,2,3\n\"4\",5,6\n7,8,9\n1,2\"2,3\n"},
+		{name: "Field count mismatch after quoted line", input: "a,b,c\n# comment\n1,2,3\n\"4\",\"5\n5\",6\n7,8\n", opts: ReadOptions{Comment: '#'}},
+		{name: "Empty input", input: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, oneByte := range []bool{false, true} {
+				input := func() io.Reader {
+					if oneByte {
+						return iotest.OneByteReader(strings.NewReader(tt.input))
+					}
+					return strings.NewReader(tt.input)
+				}
+
+				wantReader, err := newCSVReader(input(), tt.opts)
+				if err != nil {
+					t.Fatalf("newCSVReader() unexpected error: %v", err)
+				}
+				want, wantErr := readAllRecords(wantReader)
+
+				fastOpts := tt.opts
+				fastOpts.FastCSV, fastOpts.CountOnly = true, true
+				gotReader, err := newRecordReader(input(), fastOpts)
+				if err != nil {
+					t.Fatalf("newRecordReader() unexpected error: %v", err)
+				}
+				if _, ok := gotReader.(*fastCSVReader); !ok {
+					t.Fatalf("newRecordReader() = %T, want *fastCSVReader", gotReader)
+				}
+				got, gotErr := readAllRecords(gotReader)
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("fastCSVReader.Read() = %q, want %q", got, want)
+				}
+				if (gotErr != nil) != (wantErr != nil) || (wantErr != nil && !errors.Is(gotErr, errors.Unwrap(wantErr))) {
+					t.Errorf("fastCSVReader.Read() error = %v, want %v", gotErr, wantErr)
+				}
+
+				var gotParseErr, wantParseErr *csv.ParseError
+				if errors.As(wantErr, &wantParseErr) {
+					if !errors.As(gotErr, &gotParseErr) {
+						t.Fatalf("fastCSVReader.Read() error = %v, want *csv.ParseError", gotErr)
+					}
+					if gotParseErr.StartLine != wantParseErr.StartLine || gotParseErr.Line != wantParseErr.Line || gotParseErr.Column != wantParseErr.Column {
+						t.Errorf("fastCSVReader.Read() error at line %d (start %d) column %d, want line %d (start %d) column %d",
+							gotParseErr.Line, gotParseErr.StartLine, gotParseErr.Column, wantParseErr.Line, wantParseErr.StartLine, wantParseErr.Column)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFastCSVReaderNotUsed(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ReadOptions
+	}{
+		{name: "Without CountOnly", opts: ReadOptions{FastCSV: true}},
+		{name: "With TrimLeadingSpace", opts: ReadOptions{FastCSV: true, CountOnly: true, TrimLeadingSpace: true}},
+		{name: "Non-ASCII delimiter", opts: ReadOptions{FastCSV: true, CountOnly: true, Delimiter: '§'}},
+		{name: "Comment equal to delimiter", opts: ReadOptions{FastCSV: true, CountOnly: true, Comment: ','}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := newRecordReader(strings.NewReader("a,b\n1,2\n"), tt.opts)
+			if err != nil {
+				t.Fatalf("newRecordReader() unexpected error: %v", err)
+			}
+			if _, ok := reader.(*fastCSVReader); ok {
+				t.Errorf("newRecordReader() = %T, want *csv.Reader", reader)
+			}
+		})
+	}
+}
+
+func TestFastCSVCounts(t *testing.T) {
+	quoted := generateCSV(500, 7) + `First,"Last, Jr.",quoted@example1.com,male,10.0.0.1` + "\n" + generateCSV(500, 11)[len("first_name,last_name,email,gender,ip_address\n"):]
+	invalid := generateCSV(100, 5) + "First,Last,not-an-email,male,10.0.0.1\n"
+	footer := generateCSV(100, 5) + "Total: 100\n"
+
+	tests := []struct {
+		name  string
+		input string
+		opts  ReadOptions
+	}{
+		{name: "Simple file", input: generateCSV(1000, 13)},
+		{name: "Quoted line mid-file", input: quoted},
+		{name: "Skip invalid", input: invalid, opts: ReadOptions{SkipInvalid: true}},
+		{name: "Stop at invalid", input: invalid},
+		{name: "Ignored footer", input: footer, opts: ReadOptions{IgnoreFooter: true}},
+		{name: "Auto delimiter", input: strings.ReplaceAll(generateCSV(100, 5), ",", ";"), opts: ReadOptions{AutoDelimiter: true}},
+		{name: "Mapped columns", input: generateCSV(100, 5), opts: ReadOptions{MapColumnsByHeader: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wantStats, gotStats ReadStats
+
+			opts := tt.opts
+			opts.CountOnly, opts.Stats = true, &wantStats
+			want, wantErr := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(tt.input), opts)
+
+			opts.FastCSV, opts.Stats = true, &gotStats
+			got, gotErr := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(tt.input), opts)
+
+			if (gotErr != nil) != (wantErr != nil) {
+				t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() with FastCSV error = %v, want %v", gotErr, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with FastCSV = %v, want %v", got, want)
+			}
+			if gotStats != wantStats {
+				t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with FastCSV stats = %+v, want %+v", gotStats, wantStats)
+			}
+		})
+	}
+}
+
+// Benchmark for counting domains with and without the FastCSV splitter
+func BenchmarkReadAndCountDomainsFromCSVFastCSV(b *testing.B) {
+	input := generateCSV(100000, 1000)
+
+	for _, fastCSV := range []bool{false, true} {
+		b.Run(fmt.Sprintf("FastCSV=%v", fastCSV), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{CountOnly: true, FastCSV: fastCSV})
+				if err != nil {
+					b.Fatalf("failed to read and count domains: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package customerimporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Const "THOUSANDS_SEPARATOR" signifies the character used to group digits of counts in text table output.
+const THOUSANDS_SEPARATOR = ','
+
+// Type "TableOptions" groups optional settings for writing domain counts as a text table.
+type TableOptions struct {
+	// GroupThousands formats counts with thousands separators, e.g. 1,200,000.
+	GroupThousands bool
+}
+
+// Function "formatCount" renders a count as decimal string, optionally grouping digits by thousands.
+func formatCount(count int, groupThousands bool) string {
+	digits := strconv.Itoa(count)
+	if !groupThousands {
+		return digits
+	}
+
+	sign := ""
+	if count < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	grouped := make([]byte, 0, len(digits)+len(digits)/3)
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, THOUSANDS_SEPARATOR)
+		}
+		grouped = append(grouped, digits[i])
+	}
+
+	return sign + string(grouped)
+}
+
+// Function "WriteDomainCountsTable" writes domain counts to "w" as an aligned, human readable text table.
+func WriteDomainCountsTable(w io.Writer, counts []domainCount, opts TableOptions) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "DOMAIN\tCOUNT")
+	for _, dc := range counts {
+		fmt.Fprintf(tw, "%s\t%s\n", dc.Domain, formatCount(dc.Count, opts.GroupThousands))
+	}
+
+	return tw.Flush()
+}
+
+// Const "HISTOGRAM_BAR" signifies the character bars of "WriteDomainHistogram" are drawn with.
+const HISTOGRAM_BAR = "█"
+
+// Const "DEFAULT_HISTOGRAM_BARS" signifies the number of domains "WriteDomainHistogram" draws bars for.
+const DEFAULT_HISTOGRAM_BARS = 20
+
+// Function "WriteDomainHistogram" writes domain counts to "w" as an ASCII bar chart, e.g. "example.com  ████████ 1200",
+// for up to "DEFAULT_HISTOGRAM_BARS" first domains. See "WriteDomainHistogramTop" for details.
+func WriteDomainHistogram(w io.Writer, counts []domainCount, maxWidth int) error {
+	return WriteDomainHistogramTop(w, counts, maxWidth, DEFAULT_HISTOGRAM_BARS)
+}
+
+// Function "WriteDomainHistogramTop" works like "WriteDomainHistogram", drawing bars for up to "topN" first domains,
+// or all of them when "topN" is not positive. Bar lengths are scaled so the largest count drawn takes "maxWidth" characters,
+// with every non-zero count getting at least one.
+func WriteDomainHistogramTop(w io.Writer, counts []domainCount, maxWidth int, topN int) error {
+	if maxWidth <= 0 {
+		return fmt.Errorf("invalid histogram width %d", maxWidth)
+	}
+
+	if topN > 0 && len(counts) > topN {
+		counts = counts[:topN]
+	}
+
+	maxCount := 0
+	for _, dc := range counts {
+		maxCount = max(maxCount, dc.Count)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, dc := range counts {
+		length := 0
+		if dc.Count > 0 {
+			// round to the nearest character, multiplying first to keep integer precision
+			length = max((dc.Count*maxWidth+maxCount/2)/maxCount, 1)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s %d\n", dc.Domain, strings.Repeat(HISTOGRAM_BAR, length), dc.Count)
+	}
+
+	return tw.Flush()
+}
+
+// Function "WriteDomainCountsCSV" writes domain counts to "w" as CSV with a header line. Counts are written as raw integers.
+func WriteDomainCountsCSV(w io.Writer, counts []domainCount) error {
+	csvWriter := csv.NewWriter(w)
+
+	err := csvWriter.Write([]string{"domain", "count"})
+	if err != nil {
+		return err
+	}
+
+	for _, dc := range counts {
+		err = csvWriter.Write([]string{dc.Domain, strconv.Itoa(dc.Count)})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// Variable "markdownCellEscaper" escapes backslashes and pipes in Markdown table cells, and replaces line feeds that would end the row.
+var markdownCellEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ")
+
+// Function "WriteDomainCountsMarkdown" writes domain counts to "w" as a Markdown table with a header and separator row,
+// e.g. to paste into GitHub issues. Counts are right aligned and written as raw integers.
+func WriteDomainCountsMarkdown(w io.Writer, counts []domainCount) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "| Domain | Count |")
+	fmt.Fprintln(bw, "| --- | ---: |")
+	for _, dc := range counts {
+		fmt.Fprintf(bw, "| %s | %d |\n", markdownCellEscaper.Replace(dc.Domain), dc.Count)
+	}
+
+	return bw.Flush()
+}
+
+// Function "StreamCountsToWriterCSV" counts domains like "ReadAndCountDomainsFromCSV" and writes them to "w" like "WriteDomainCountsCSV".
+// Counts are only final at the end of input, so nothing is written before the whole file is read, but lines are discarded
+// as they are counted and output goes straight to "w", without holding customers or an output buffer in memory.
+// Nothing is written if reading fails.
+func StreamCountsToWriterCSV(r io.Reader, w io.Writer) error {
+	domainCounter := NewDomainCounter()
+
+	err := countDomainsFromCSV(r, ReadOptions{}, domainCounter)
+	if err != nil {
+		return err
+	}
+
+	return WriteDomainCountsCSV(w, domainCounter.Counts())
+}
+
+// Function "TeeReadAndCount" counts domains like "ReadAndCountDomainsFromCSV" and, in the same pass, writes each valid customer
+// to "valid" as CSV with a header line, in the fixed column order read by "ReadCustomersFromCSV". Gender is written by name.
+// Customers written before an error are kept in "valid".
+func TeeReadAndCount(r io.Reader, valid io.Writer) ([]domainCount, error) {
+	csvWriter := csv.NewWriter(valid)
+	domainCounter := NewDomainCounter()
+
+	err := csvWriter.Write([]string{HEADER_FIRST_NAME, HEADER_LAST_NAME, HEADER_EMAIL, HEADER_GENDER, HEADER_IP_ADDRESS})
+	if err != nil {
+		return nil, err
+	}
+
+	err = processCustomersFromCSV(r, ReadOptions{}, func(customer customer) error {
+		domainCounter.Add(customer.GetDomain())
+		return csvWriter.Write([]string{customer.FirstName, customer.LastName, string(customer.Email), customer.Gender.String(), customer.IPString()})
+	})
+	csvWriter.Flush()
+	if err != nil {
+		return nil, err
+	}
+	if err := csvWriter.Error(); err != nil {
+		return nil, err
+	}
+
+	return domainCounter.Counts(), nil
+}
+
+// Function "WriteDomainCountsJSON" writes domain counts to "w" as a JSON array of objects, preserving their order.
+// Counts are written as raw integers.
+func WriteDomainCountsJSON(w io.Writer, counts []domainCount) error {
+	if counts == nil {
+		counts = []domainCount{}
+	}
+
+	return json.NewEncoder(w).Encode(counts)
+}
+
+// Function "WriteDomainCountsJSONMap" writes domain counts to "w" as a JSON object keyed by domain, e.g. {"example.com":1200}.
+// JSON objects are unordered and keys are written sorted alphabetically, use "WriteDomainCountsJSON" when order matters.
+// Counts of a domain listed more than once are summed.
+func WriteDomainCountsJSONMap(w io.Writer, counts []domainCount) error {
+	return json.NewEncoder(w).Encode(DomainCountsToMap(counts))
+}
+
+// Variable "prometheusMetricNameRegex" matches valid Prometheus metric names.
+var prometheusMetricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Variable "prometheusLabelEscaper" escapes backslashes, double quotes and line feeds in Prometheus label values.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Function "WriteDomainCountsPrometheus" writes domain counts to "w" in Prometheus text exposition format as a gauge
+// named "metricName" with a "domain" label, e.g. customer_domain_count{domain="example.com"} 1200.
+func WriteDomainCountsPrometheus(w io.Writer, counts []domainCount, metricName string) error {
+	if !prometheusMetricNameRegex.MatchString(metricName) {
+		return fmt.Errorf("invalid prometheus metric name %q", metricName)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# TYPE %s gauge\n", metricName)
+	for _, dc := range counts {
+		fmt.Fprintf(bw, "%s{domain=\"%s\"} %d\n", metricName, prometheusLabelEscaper.Replace(dc.Domain), dc.Count)
+	}
+
+	return bw.Flush()
+}
+
+// Type "customerJSON" is the JSON representation of "customer", with fields named like CSV header columns.
+type customerJSON struct {
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name,omitempty"`
+	LastName   string `json:"last_name"`
+	Email      string `json:"email"`
+	Gender     string `json:"gender"`
+	IPAddress  string `json:"ip_address"`
+	Country    string `json:"country,omitempty"`
+}
+
+// Function "newCustomerJSON" converts customer to its JSON representation.
+func newCustomerJSON(c customer) customerJSON {
+	return customerJSON{
+		FirstName:  c.FirstName,
+		MiddleName: c.MiddleName,
+		LastName:   c.LastName,
+		Email:      string(c.Email),
+		Gender:     c.Gender.String(),
+		IPAddress:  c.IPString(),
+		Country:    c.Country,
+	}
+}
+
+// Function "WriteCustomersNDJSON" writes customers to "w" as newline delimited JSON, one object per line,
+// e.g. for piping into other tools. Fields are named like CSV header columns, gender is written by name.
+func WriteCustomersNDJSON(w io.Writer, customers []customer) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	for _, c := range customers {
+		err := encoder.Encode(newCustomerJSON(c))
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Function "ConvertCSVToNDJSON" reads CSV file with given options and writes each customer to "w" like "WriteCustomersNDJSON",
+// as soon as its line is parsed, without holding all customers in memory. Lines written before an error are kept in "w".
+func ConvertCSVToNDJSON(r io.Reader, w io.Writer, opts ReadOptions) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	err := processCustomersFromCSV(r, opts, func(customer customer) error {
+		return encoder.Encode(newCustomerJSON(customer))
+	})
+	if err != nil {
+		bw.Flush()
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Type "ImportResult" groups domain counts with statistics of reading the CSV file they were counted from.
+type ImportResult struct {
+	Counts []domainCount
+	Stats  ReadStats
+}
+
+// Function "skippedSummary" describes the number of skipped lines, e.g. "3 rows skipped".
+func skippedSummary(skipped int, groupThousands bool) string {
+	if skipped == 1 {
+		return "1 row skipped"
+	}
+
+	return formatCount(skipped, groupThousands) + " rows skipped"
+}
+
+// Function "WriteImportResultTable" writes domain counts like "WriteDomainCountsTable",
+// followed by a footer with the number of skipped lines when any were skipped.
+func WriteImportResultTable(w io.Writer, result ImportResult, opts TableOptions) error {
+	err := WriteDomainCountsTable(w, result.Counts, opts)
+	if err != nil {
+		return err
+	}
+
+	if result.Stats.Skipped > 0 {
+		_, err = fmt.Fprintf(w, "\n%s\n", skippedSummary(result.Stats.Skipped, opts.GroupThousands))
+	}
+
+	return err
+}
+
+// Function "WriteImportResultCSV" writes domain counts like "WriteDomainCountsCSV", followed by a footer
+// comment line with the number of skipped lines when any were skipped. It can be ignored with "csv.Reader.Comment" set to '#'.
+func WriteImportResultCSV(w io.Writer, result ImportResult) error {
+	err := WriteDomainCountsCSV(w, result.Counts)
+	if err != nil {
+		return err
+	}
+
+	if result.Stats.Skipped > 0 {
+		_, err = fmt.Fprintf(w, "# %s\n", skippedSummary(result.Stats.Skipped, false))
+	}
+
+	return err
+}
+
+// Function "WriteImportResultJSON" writes domain counts to "w" as a JSON object, with the array of counts
+// under "counts" key and the number of skipped lines under "skipped" key, omitted when none were skipped.
+func WriteImportResultJSON(w io.Writer, result ImportResult) error {
+	counts := result.Counts
+	if counts == nil {
+		counts = []domainCount{}
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		Counts  []domainCount `json:"counts"`
+		Skipped int           `json:"skipped,omitempty"`
+	}{
+		Counts:  counts,
+		Skipped: result.Stats.Skipped,
+	})
+}
+
+// Function "WriteImportSummaryJSON" writes a single JSON object summarizing an import to "w", e.g. as an API payload:
+// "total" number of customers, "uniqueDomains" number of domains in "counts" and "top" array of up to "topN" first counts,
+// like "TopDomains" returns them. All counts are included when "topN" is not positive.
+func WriteImportSummaryJSON(w io.Writer, counts []domainCount, total, topN int) error {
+	top := TopDomains(counts, topN)
+
+	return json.NewEncoder(w).Encode(struct {
+		Total         int           `json:"total"`
+		UniqueDomains int           `json:"uniqueDomains"`
+		Top           []domainCount `json:"top"`
+	}{
+		Total:         total,
+		UniqueDomains: top.Total,
+		Top:           top.Domains,
+	})
+}
@@ -0,0 +1,691 @@
+package customerimporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		name           string
+		count          int
+		groupThousands bool
+		want           string
+	}{
+		{name: "Raw count", count: 1200000, groupThousands: false, want: "1200000"},
+		{name: "Grouped millions", count: 1200000, groupThousands: true, want: "1,200,000"},
+		{name: "Grouped thousands", count: 12345, groupThousands: true, want: "12,345"},
+		{name: "Below a thousand", count: 999, groupThousands: true, want: "999"},
+		{name: "Zero", count: 0, groupThousands: true, want: "0"},
+		{name: "Negative", count: -1234567, groupThousands: true, want: "-1,234,567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatCount(tt.count, tt.groupThousands)
+			if got != tt.want {
+				t.Errorf("formatCount(%d, %v) = %v, want %v", tt.count, tt.groupThousands, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainCountsTable(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example.com", Count: 1200000},
+		{Domain: "foo.org", Count: 30},
+	}
+
+	tests := []struct {
+		name string
+		opts TableOptions
+		want string
+	}{
+		{
+			name: "Raw counts",
+			opts: TableOptions{},
+			want: "DOMAIN       COUNT\n" +
+				"example.com  1200000\n" +
+				"foo.org      30\n",
+		},
+		{
+			name: "Grouped counts",
+			opts: TableOptions{GroupThousands: true},
+			want: "DOMAIN       COUNT\n" +
+				"example.com  1,200,000\n" +
+				"foo.org      30\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainCountsTable(&buf, counts, tt.opts)
+			if err != nil {
+				t.Fatalf("WriteDomainCountsTable() unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteDomainCountsTable() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainHistogram(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example.com", Count: 1200},
+		{Domain: "foo.org", Count: 600},
+		{Domain: "bar.io", Count: 300},
+		{Domain: "tiny.net", Count: 1},
+	}
+
+	tests := []struct {
+		name     string
+		maxWidth int
+		topN     int
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Proportional bars",
+			maxWidth: 8,
+			topN:     0,
+			want: "example.com  ████████ 1200\n" +
+				"foo.org      ████ 600\n" +
+				"bar.io       ██ 300\n" +
+				"tiny.net     █ 1\n",
+			wantErr: false,
+		},
+		{
+			name:     "Limited to top domains",
+			maxWidth: 4,
+			topN:     2,
+			want: "example.com  ████ 1200\n" +
+				"foo.org      ██ 600\n",
+			wantErr: false,
+		},
+		{
+			name:     "Invalid width",
+			maxWidth: 0,
+			topN:     0,
+			want:     "",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainHistogramTop(&buf, counts, tt.maxWidth, tt.topN)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteDomainHistogramTop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteDomainHistogramTop() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run("Default number of bars", func(t *testing.T) {
+		var manyCounts []domainCount
+		for i := 0; i < 2*DEFAULT_HISTOGRAM_BARS; i++ {
+			manyCounts = append(manyCounts, domainCount{Domain: "example.com", Count: 1})
+		}
+
+		var buf bytes.Buffer
+		if err := WriteDomainHistogram(&buf, manyCounts, 10); err != nil {
+			t.Fatalf("WriteDomainHistogram() unexpected error: %v", err)
+		}
+
+		if lines := strings.Count(buf.String(), "\n"); lines != DEFAULT_HISTOGRAM_BARS {
+			t.Errorf("WriteDomainHistogram() wrote %d bars, want %d", lines, DEFAULT_HISTOGRAM_BARS)
+		}
+	})
+}
+
+func TestWriteDomainCountsCSV(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example.com", Count: 1200000},
+		{Domain: "foo.org", Count: 30},
+	}
+	want := "domain,count\nexample.com,1200000\nfoo.org,30\n"
+
+	var buf bytes.Buffer
+	err := WriteDomainCountsCSV(&buf, counts)
+	if err != nil {
+		t.Fatalf("WriteDomainCountsCSV() unexpected error: %v", err)
+	}
+
+	if buf.String() != want {
+		t.Errorf("WriteDomainCountsCSV() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteDomainCountsMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []domainCount
+		want   string
+	}{
+		{
+			name: "Domain counts",
+			counts: []domainCount{
+				{Domain: "example.com", Count: 1200000},
+				{Domain: "foo.org", Count: 30},
+			},
+			want: "| Domain | Count |\n| --- | ---: |\n| example.com | 1200000 |\n| foo.org | 30 |\n",
+		},
+		{
+			name:   "Escaped domain",
+			counts: []domainCount{{Domain: `a|b\c`, Count: 1}},
+			want:   "| Domain | Count |\n| --- | ---: |\n| a\\|b\\\\c | 1 |\n",
+		},
+		{
+			name:   "No counts",
+			counts: nil,
+			want:   "| Domain | Count |\n| --- | ---: |\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainCountsMarkdown(&buf, tt.counts)
+			if err != nil {
+				t.Fatalf("WriteDomainCountsMarkdown() unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteDomainCountsMarkdown() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainCountsJSONMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []domainCount
+		want   map[string]int
+	}{
+		{
+			name: "Domain counts",
+			counts: []domainCount{
+				{Domain: "example.com", Count: 1200},
+				{Domain: "foo.org", Count: 30},
+			},
+			want: map[string]int{"example.com": 1200, "foo.org": 30},
+		},
+		{
+			name:   "No counts",
+			counts: nil,
+			want:   map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainCountsJSONMap(&buf, tt.counts)
+			if err != nil {
+				t.Fatalf("WriteDomainCountsJSONMap() unexpected error: %v", err)
+			}
+
+			var got map[string]int
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("WriteDomainCountsJSONMap() wrote invalid JSON %q: %v", buf.String(), err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WriteDomainCountsJSONMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainCountsPrometheus(t *testing.T) {
+	tests := []struct {
+		name       string
+		counts     []domainCount
+		metricName string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "Domain counts",
+			counts: []domainCount{
+				{Domain: "example.com", Count: 1200},
+				{Domain: "foo.org", Count: 30},
+			},
+			metricName: "customer_domain_count",
+			want:       "# TYPE customer_domain_count gauge\ncustomer_domain_count{domain=\"example.com\"} 1200\ncustomer_domain_count{domain=\"foo.org\"} 30\n",
+			wantErr:    false,
+		},
+		{
+			name:       "Escaped label value",
+			counts:     []domainCount{{Domain: "a\"b\\c\nd", Count: 1}},
+			metricName: "customer_domain_count",
+			want:       "# TYPE customer_domain_count gauge\ncustomer_domain_count{domain=\"a\\\"b\\\\c\\nd\"} 1\n",
+			wantErr:    false,
+		},
+		{
+			name:       "No counts",
+			counts:     nil,
+			metricName: "customer_domain_count",
+			want:       "# TYPE customer_domain_count gauge\n",
+			wantErr:    false,
+		},
+		{
+			name:       "Invalid metric name",
+			counts:     []domainCount{{Domain: "example.com", Count: 1}},
+			metricName: "customer-domain-count",
+			want:       "",
+			wantErr:    true,
+		},
+	}
+
+	sampleRegex := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*\{domain="(?:[^"\\\n]|\\[\\"n])*"\} [0-9]+$`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainCountsPrometheus(&buf, tt.counts, tt.metricName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteDomainCountsPrometheus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteDomainCountsPrometheus() = %q, want %q", buf.String(), tt.want)
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				if line != "" && !strings.HasPrefix(line, "# ") && !sampleRegex.MatchString(line) {
+					t.Errorf("WriteDomainCountsPrometheus() wrote invalid sample line %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamCountsToWriterCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Domain counts",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,first.last@example1.com,male,192.168.1.1
+First,Last,second.last@example2.com,female,192.168.1.2
+First,Last,third.last@example1.com,female,192.168.1.3`,
+			want:    "domain,count\nexample1.com,2\nexample2.com,1\n",
+			wantErr: false,
+		},
+		{
+			name:    "Empty input",
+			input:   "",
+			want:    "domain,count\n",
+			wantErr: false,
+		},
+		{
+			name: "Invalid line",
+			input: `first_name,last_name,email,gender,ip_address
+First,Last,not-an-email,male,192.168.1.1`,
+			want:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := StreamCountsToWriterCSV(strings.NewReader(tt.input), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StreamCountsToWriterCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("StreamCountsToWriterCSV() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDomainCountsJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []domainCount
+		want   string
+	}{
+		{
+			name: "Multiple domains",
+			counts: []domainCount{
+				{Domain: "example.com", Count: 1200000},
+				{Domain: "foo.org", Count: 30},
+			},
+			want: `[{"domain":"example.com","count":1200000},{"domain":"foo.org","count":30}]` + "\n",
+		},
+		{
+			name:   "No domains",
+			counts: nil,
+			want:   "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteDomainCountsJSON(&buf, tt.counts)
+			if err != nil {
+				t.Fatalf("WriteDomainCountsJSON() unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteDomainCountsJSON() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTeeReadAndCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		want       []domainCount
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name: "Valid lines",
+			input: `first_name,last_name,email,gender,ip_address
+John,Doe,john.doe@example1.com,Male,192.168.1.1
+Jane,Doe,jane.doe@Example2.com,F,192.168.1.2
+Joe,Doe,joe.doe@example1.com,,2001:db8::1`,
+			want: []domainCount{{Domain: "example1.com", Count: 2}, {Domain: "example2.com", Count: 1}},
+			wantOutput: `first_name,last_name,email,gender,ip_address
+John,Doe,john.doe@example1.com,male,192.168.1.1
+Jane,Doe,jane.doe@Example2.com,female,192.168.1.2
+Joe,Doe,joe.doe@example1.com,unknown,2001:db8::1
+`,
+			wantErr: false,
+		},
+		{
+			name:       "Empty input",
+			input:      "",
+			want:       []domainCount{},
+			wantOutput: "first_name,last_name,email,gender,ip_address\n",
+			wantErr:    false,
+		},
+		{
+			name: "Invalid line",
+			input: `first_name,last_name,email,gender,ip_address
+John,Doe,john.doe@example1.com,Male,192.168.1.1
+Jane,Doe,not-an-email,Female,192.168.1.2`,
+			want:       nil,
+			wantOutput: "first_name,last_name,email,gender,ip_address\nJohn,Doe,john.doe@example1.com,male,192.168.1.1\n",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			got, err := TeeReadAndCount(strings.NewReader(tt.input), &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TeeReadAndCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TeeReadAndCount() = %v, want %v", got, tt.want)
+			}
+			if buf.String() != tt.wantOutput {
+				t.Errorf("TeeReadAndCount() output = %q, want %q", buf.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestTeeReadAndCountRoundTrip(t *testing.T) {
+	input := generateCSV(100, 10)
+
+	var buf bytes.Buffer
+	got, err := TeeReadAndCount(strings.NewReader(input), &buf)
+	if err != nil {
+		t.Fatalf("TeeReadAndCount() unexpected error: %v", err)
+	}
+
+	want, err := ReadAndCountDomainsFromCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadAndCountDomainsFromCSV() of written customers unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TeeReadAndCount() = %v, want %v counted from written customers", got, want)
+	}
+}
+
+func TestWriteCustomersNDJSON(t *testing.T) {
+	customers := []customer{
+		{FirstName: "John", MiddleName: "Paul", LastName: "Doe", Email: "john.doe@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1").To4()},
+		{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com", Gender: unknown, IPAddress: net.ParseIP("2001:db8::1")},
+	}
+	want := []map[string]string{
+		{"first_name": "John", "middle_name": "Paul", "last_name": "Doe", "email": "john.doe@example.com", "gender": "male", "ip_address": "192.168.1.1"},
+		{"first_name": "Jane", "last_name": "Doe", "email": "jane.doe@example.com", "gender": "unknown", "ip_address": "2001:db8::1"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteCustomersNDJSON(&buf, customers)
+	if err != nil {
+		t.Fatalf("WriteCustomersNDJSON() unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("WriteCustomersNDJSON() wrote %d lines, want %d", len(lines), len(want))
+	}
+
+	for i, line := range lines {
+		var got map[string]string
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("WriteCustomersNDJSON() line %d is not valid JSON %q: %v", i+1, line, err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("WriteCustomersNDJSON() line %d = %v, want %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestConvertCSVToNDJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    []map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "Valid lines",
+			input: "first_name,last_name,email,gender,ip_address\nJohn,Doe,john.doe@example.com,Male,192.168.1.1\nJane,Doe,jane.doe@example.org,Female,2001:db8::1\n",
+			want: []map[string]string{
+				{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com", "gender": "male", "ip_address": "192.168.1.1"},
+				{"first_name": "Jane", "last_name": "Doe", "email": "jane.doe@example.org", "gender": "female", "ip_address": "2001:db8::1"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Skip invalid lines",
+			input: "first_name,last_name,email,gender,ip_address\nJohn,Doe,john.doe@example.com,Male,192.168.1.1\nJane,Doe,not-an-email,Female,192.168.1.2\n",
+			opts:  ReadOptions{SkipInvalid: true},
+			want: []map[string]string{
+				{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com", "gender": "male", "ip_address": "192.168.1.1"},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Stop at invalid line",
+			input: "first_name,last_name,email,gender,ip_address\nJohn,Doe,john.doe@example.com,Male,192.168.1.1\nJane,Doe,not-an-email,Female,192.168.1.2\n",
+			want: []map[string]string{
+				{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com", "gender": "male", "ip_address": "192.168.1.1"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := ConvertCSVToNDJSON(strings.NewReader(tt.input), &buf, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertCSVToNDJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("ConvertCSVToNDJSON() wrote %d lines, want %d", len(lines), len(tt.want))
+			}
+
+			for i, line := range lines {
+				var got map[string]string
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("ConvertCSVToNDJSON() line %d is not valid JSON %q: %v", i+1, line, err)
+				}
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("ConvertCSVToNDJSON() line %d = %v, want %v", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteImportResult(t *testing.T) {
+	counts := []domainCount{{Domain: "example.com", Count: 2}}
+
+	tests := []struct {
+		name      string
+		skipped   int
+		wantTable string
+		wantCSV   string
+		wantJSON  string
+	}{
+		{
+			name:      "No skipped lines",
+			skipped:   0,
+			wantTable: "DOMAIN       COUNT\nexample.com  2\n",
+			wantCSV:   "domain,count\nexample.com,2\n",
+			wantJSON:  `{"counts":[{"domain":"example.com","count":2}]}` + "\n",
+		},
+		{
+			name:      "Single skipped line",
+			skipped:   1,
+			wantTable: "DOMAIN       COUNT\nexample.com  2\n\n1 row skipped\n",
+			wantCSV:   "domain,count\nexample.com,2\n# 1 row skipped\n",
+			wantJSON:  `{"counts":[{"domain":"example.com","count":2}],"skipped":1}` + "\n",
+		},
+		{
+			name:      "Many skipped lines",
+			skipped:   1500,
+			wantTable: "DOMAIN       COUNT\nexample.com  2\n\n1,500 rows skipped\n",
+			wantCSV:   "domain,count\nexample.com,2\n# 1500 rows skipped\n",
+			wantJSON:  `{"counts":[{"domain":"example.com","count":2}],"skipped":1500}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ImportResult{Counts: counts, Stats: ReadStats{Lines: 2 + tt.skipped, Skipped: tt.skipped}}
+
+			var table, csv, json bytes.Buffer
+			if err := WriteImportResultTable(&table, result, TableOptions{GroupThousands: true}); err != nil {
+				t.Fatalf("WriteImportResultTable() unexpected error: %v", err)
+			}
+			if err := WriteImportResultCSV(&csv, result); err != nil {
+				t.Fatalf("WriteImportResultCSV() unexpected error: %v", err)
+			}
+			if err := WriteImportResultJSON(&json, result); err != nil {
+				t.Fatalf("WriteImportResultJSON() unexpected error: %v", err)
+			}
+
+			if table.String() != tt.wantTable {
+				t.Errorf("WriteImportResultTable() = %q, want %q", table.String(), tt.wantTable)
+			}
+			if csv.String() != tt.wantCSV {
+				t.Errorf("WriteImportResultCSV() = %q, want %q", csv.String(), tt.wantCSV)
+			}
+			if json.String() != tt.wantJSON {
+				t.Errorf("WriteImportResultJSON() = %q, want %q", json.String(), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestWriteImportSummaryJSON(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example1.com", Count: 600},
+		{Domain: "example2.com", Count: 300},
+		{Domain: "example3.com", Count: 100},
+	}
+
+	tests := []struct {
+		name   string
+		counts []domainCount
+		total  int
+		topN   int
+		want   string
+	}{
+		{
+			name:   "Top domains",
+			counts: counts,
+			total:  1000,
+			topN:   2,
+			want:   `{"total":1000,"uniqueDomains":3,"top":[{"domain":"example1.com","count":600},{"domain":"example2.com","count":300}]}` + "\n",
+		},
+		{
+			name:   "All domains",
+			counts: counts,
+			total:  1000,
+			topN:   0,
+			want:   `{"total":1000,"uniqueDomains":3,"top":[{"domain":"example1.com","count":600},{"domain":"example2.com","count":300},{"domain":"example3.com","count":100}]}` + "\n",
+		},
+		{
+			name:   "No domains",
+			counts: nil,
+			total:  0,
+			topN:   10,
+			want:   `{"total":0,"uniqueDomains":0,"top":[]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteImportSummaryJSON(&buf, tt.counts, tt.total, tt.topN)
+			if err != nil {
+				t.Fatalf("WriteImportSummaryJSON() unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("WriteImportSummaryJSON() = %s, want %s", buf.String(), tt.want)
+			}
+
+			var summary struct {
+				Total         int           `json:"total"`
+				UniqueDomains int           `json:"uniqueDomains"`
+				Top           []domainCount `json:"top"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &summary); err != nil {
+				t.Fatalf("WriteImportSummaryJSON() wrote invalid JSON: %v", err)
+			}
+			if summary.Total != tt.total || summary.UniqueDomains != len(tt.counts) {
+				t.Errorf("WriteImportSummaryJSON() total = %d, uniqueDomains = %d, want %d, %d", summary.Total, summary.UniqueDomains, tt.total, len(tt.counts))
+			}
+		})
+	}
+}
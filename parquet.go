@@ -0,0 +1,76 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Const "PARQUET_READ_BATCH_SIZE" signifies the number of rows read from Parquet file at once.
+const PARQUET_READ_BATCH_SIZE = 256
+
+// Type "parquetCustomer" describes a row of Parquet file with customer data. Columns are named like CSV header fields.
+type parquetCustomer struct {
+	FirstName  string `parquet:"first_name"`
+	MiddleName string `parquet:"middle_name,optional"`
+	LastName   string `parquet:"last_name"`
+	Email      string `parquet:"email"`
+	Gender     string `parquet:"gender,optional"`
+	IPAddress  string `parquet:"ip_address"`
+}
+
+// Variable "parquetColumnIndex" reflects the order in which "parquetCustomer" fields are passed to "parseCustomerLineWithOptions".
+var parquetColumnIndex = columnIndex{
+	firstName:  0,
+	middleName: 1,
+	lastName:   2,
+	email:      3,
+	gender:     4,
+	ipAddress:  5,
+	country:    NO_COLUMN,
+}
+
+// Method "fields" returns values of a Parquet row in the order of "parquetColumnIndex", reusing "fields" slice.
+func (pc parquetCustomer) fields(fields []string) []string {
+	return append(fields[:0], pc.FirstName, pc.MiddleName, pc.LastName, pc.Email, pc.Gender, pc.IPAddress)
+}
+
+// Function "ReadCustomersFromParquet" reads Parquet file of given size and maps its rows to "customer" structs,
+// validating them the same way as CSV lines. Rows are numbered from 1 in returned "ParseError".
+func ReadCustomersFromParquet(r io.ReaderAt, size int64) ([]customer, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	reader := parquet.NewGenericReader[parquetCustomer](file)
+	defer reader.Close()
+
+	customers := []customer{}
+	rows := make([]parquetCustomer, PARQUET_READ_BATCH_SIZE)
+	var fields []string
+	rowNumber := 0
+
+	for {
+		n, err := reader.Read(rows)
+		for _, row := range rows[:n] {
+			rowNumber++
+
+			fields = row.fields(fields)
+			customer, err := parseCustomerLineWithOptions(fields, rowNumber, ReadOptions{}, parquetColumnIndex)
+			if err != nil {
+				return nil, err
+			}
+			customers = append(customers, customer)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return customers, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read parquet file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package customerimporter
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Function "generateParquet" writes given rows to an in-memory Parquet file.
+func generateParquet(t *testing.T, rows []parquetCustomer) *bytes.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := parquet.NewGenericWriter[parquetCustomer](&buf)
+	if _, err := writer.Write(rows); err != nil {
+		t.Fatalf("failed to write parquet rows: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close parquet writer: %v", err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadCustomersFromParquet(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []parquetCustomer
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name: "Valid rows",
+			rows: []parquetCustomer{
+				{FirstName: "John", MiddleName: "Paul", LastName: "Doe", Email: "john.doe@example.com", Gender: "Male", IPAddress: "192.168.1.1"},
+				{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@Example.org", Gender: "Female", IPAddress: "2001:db8::1"},
+			},
+			want: []customer{
+				{FirstName: "John", MiddleName: "Paul", LastName: "Doe", Email: "john.doe@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1").To4()},
+				{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@Example.org", Gender: female, IPAddress: net.ParseIP("2001:db8::1")},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "No rows",
+			rows:    []parquetCustomer{},
+			want:    []customer{},
+			wantErr: false,
+		},
+		{
+			name: "Invalid email",
+			rows: []parquetCustomer{
+				{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", IPAddress: "192.168.1.1"},
+				{FirstName: "Jane", LastName: "Doe", Email: "not-an-email", IPAddress: "192.168.1.2"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := generateParquet(t, tt.rows)
+
+			got, err := ReadCustomersFromParquet(file, file.Size())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCustomersFromParquet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCustomersFromParquet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCustomersFromParquetParseError(t *testing.T) {
+	file := generateParquet(t, []parquetCustomer{
+		{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", IPAddress: "192.168.1.1"},
+		{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com", IPAddress: "not-an-ip"},
+	})
+
+	_, err := ReadCustomersFromParquet(file, file.Size())
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ReadCustomersFromParquet() error = %v, want *ParseError", err)
+	}
+	if parseErr.Line != 2 || parseErr.Field != FIELD_IP_ADDRESS {
+		t.Errorf("ReadCustomersFromParquet() error at row %d field %q, want row 2 field %q", parseErr.Line, parseErr.Field, FIELD_IP_ADDRESS)
+	}
+}
+
+func TestReadCustomersFromParquetInvalidFile(t *testing.T) {
+	file := bytes.NewReader([]byte("first_name,last_name,email,gender,ip_address\n"))
+
+	if _, err := ReadCustomersFromParquet(file, file.Size()); err == nil {
+		t.Errorf("ReadCustomersFromParquet() error = nil, want error")
+	}
+}
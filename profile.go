@@ -0,0 +1,78 @@
+package customerimporter
+
+import "io"
+
+// Const "PROFILE_SAMPLE_SIZE" signifies the number of distinct sample values "ProfileCSV" keeps per column.
+const PROFILE_SAMPLE_SIZE = 3
+
+// Type "ColumnStats" describes values found in a single column of CSV file.
+type ColumnStats struct {
+	// Name is the column name from CSV header.
+	Name string
+	// NonEmpty is the number of lines with a non-empty value in the column.
+	NonEmpty int
+	// Distinct is the number of distinct non-empty values in the column.
+	Distinct int
+	// Samples holds up to "PROFILE_SAMPLE_SIZE" first distinct non-empty values, in order of appearance.
+	Samples []string
+}
+
+// Type "ColumnProfile" describes the contents of CSV file column by column, see "ProfileCSV".
+type ColumnProfile struct {
+	// Lines is the number of data lines, excluding headers.
+	Lines int
+	// Columns holds statistics of columns in the order of CSV header.
+	Columns []ColumnStats
+}
+
+// Function "ProfileCSV" scans CSV file and reports statistics of every column, without validating or parsing lines into customers,
+// so ops can check a file before importing it. The file structure is read according to "ReadOptions", e.g. its delimiter.
+// Distinct values of every column are kept in memory while scanning.
+func ProfileCSV(r io.Reader, opts ReadOptions) (ColumnProfile, error) {
+	reader, err := newCSVReader(r, opts)
+	if err != nil {
+		return ColumnProfile{}, err
+	}
+
+	profile := ColumnProfile{Columns: []ColumnStats{}}
+	var distinct []map[string]bool
+
+	processHeader := func(csvHeader []string) error {
+		profile.Columns = make([]ColumnStats, len(csvHeader))
+		distinct = make([]map[string]bool, len(csvHeader))
+		for i, name := range csvHeader {
+			profile.Columns[i] = ColumnStats{Name: name, Samples: []string{}}
+			distinct[i] = map[string]bool{}
+		}
+		return nil
+	}
+
+	processLine := func(csvLine []string, csvLineNumber int) error {
+		profile.Lines++
+
+		for i, value := range csvLine {
+			if value == "" {
+				continue
+			}
+
+			column := &profile.Columns[i]
+			column.NonEmpty++
+
+			if !distinct[i][value] {
+				distinct[i][value] = true
+				column.Distinct++
+				if len(column.Samples) < PROFILE_SAMPLE_SIZE {
+					column.Samples = append(column.Samples, value)
+				}
+			}
+		}
+		return nil
+	}
+
+	err = processCSVFile(reader, opts, processHeader, processLine)
+	if err != nil {
+		return ColumnProfile{}, err
+	}
+
+	return profile, nil
+}
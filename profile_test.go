@@ -0,0 +1,78 @@
+package customerimporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    ReadOptions
+		want    ColumnProfile
+		wantErr bool
+	}{
+		{
+			name: "Small file",
+			input: `first_name,last_name,email,gender,ip_address
+Anna,Smith,anna@example.com,female,192.168.1.1
+Jan,Smith,jan@example.com,,192.168.1.2
+Anna,Doe,not-an-email,female,
+Zoe,Brown,zoe@example.org,female,10.0.0.1
+Adam,Green,adam@example.org,male,10.0.0.2`,
+			opts: ReadOptions{},
+			want: ColumnProfile{
+				Lines: 5,
+				Columns: []ColumnStats{
+					{Name: "first_name", NonEmpty: 5, Distinct: 4, Samples: []string{"Anna", "Jan", "Zoe"}},
+					{Name: "last_name", NonEmpty: 5, Distinct: 4, Samples: []string{"Smith", "Doe", "Brown"}},
+					{Name: "email", NonEmpty: 5, Distinct: 5, Samples: []string{"anna@example.com", "jan@example.com", "not-an-email"}},
+					{Name: "gender", NonEmpty: 4, Distinct: 2, Samples: []string{"female", "male"}},
+					{Name: "ip_address", NonEmpty: 4, Distinct: 4, Samples: []string{"192.168.1.1", "192.168.1.2", "10.0.0.1"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Semicolon delimiter",
+			input: "name;email\nAnna;anna@example.com\n",
+			opts:  ReadOptions{Delimiter: ';'},
+			want: ColumnProfile{
+				Lines: 1,
+				Columns: []ColumnStats{
+					{Name: "name", NonEmpty: 1, Distinct: 1, Samples: []string{"Anna"}},
+					{Name: "email", NonEmpty: 1, Distinct: 1, Samples: []string{"anna@example.com"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Empty file",
+			input:   "",
+			opts:    ReadOptions{},
+			want:    ColumnProfile{Columns: []ColumnStats{}},
+			wantErr: false,
+		},
+		{
+			name:    "Inconsistent field count",
+			input:   "name,email\nAnna\n",
+			opts:    ReadOptions{},
+			want:    ColumnProfile{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProfileCSV(strings.NewReader(tt.input), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProfileCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProfileCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
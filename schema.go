@@ -0,0 +1,84 @@
+package customerimporter
+
+import (
+	"fmt"
+	"io"
+)
+
+// Type "ColumnSpec" describes a single column of CSV file: its header "Name", matched like in "ReadOptions.MapColumnsByHeader",
+// and the customer "Field" it is read into, one of "FIELD_*" names except "FIELD_CUSTOMER". Of columns with the same name
+// the first one is read.
+// A "Required" column must be present in the header, an optional one is skipped when it is missing.
+type ColumnSpec struct {
+	Name     string
+	Field    string
+	Required bool
+}
+
+// Type "Schema" describes the columns of CSV file in any order. Customer fields without a column in the file are left empty
+// and not validated, while values of columns that are present are validated like with the fixed column order.
+type Schema struct {
+	Columns []ColumnSpec
+}
+
+// Method "mapColumns" finds positions of schema columns in CSV header, failing when the schema is invalid
+// or a required column is missing.
+func (s Schema) mapColumns(csvHeader []string) (columnIndex, error) {
+	columns := columnIndex{
+		firstName:  NO_COLUMN,
+		middleName: NO_COLUMN,
+		lastName:   NO_COLUMN,
+		email:      NO_COLUMN,
+		gender:     NO_COLUMN,
+		ipAddress:  NO_COLUMN,
+		country:    NO_COLUMN,
+	}
+
+	positions := make(map[string]int, len(csvHeader))
+	for i, name := range csvHeader {
+		name = normalizeHeaderName(name)
+		if _, exists := positions[name]; !exists {
+			positions[name] = i
+		}
+	}
+
+	fields := map[string]*int{
+		FIELD_FIRST_NAME:  &columns.firstName,
+		FIELD_MIDDLE_NAME: &columns.middleName,
+		FIELD_LAST_NAME:   &columns.lastName,
+		FIELD_EMAIL:       &columns.email,
+		FIELD_GENDER:      &columns.gender,
+		FIELD_IP_ADDRESS:  &columns.ipAddress,
+		FIELD_COUNTRY:     &columns.country,
+	}
+	mapped := make(map[string]bool, len(s.Columns))
+
+	for _, spec := range s.Columns {
+		field, ok := fields[spec.Field]
+		if !ok {
+			return columnIndex{}, fmt.Errorf("schema column %q has unknown field %q", spec.Name, spec.Field)
+		}
+		if mapped[spec.Field] {
+			return columnIndex{}, fmt.Errorf("schema maps more than one column to field %q", spec.Field)
+		}
+		mapped[spec.Field] = true
+
+		position, found := positions[normalizeHeaderName(spec.Name)]
+		if !found {
+			if spec.Required {
+				return columnIndex{}, fmt.Errorf("missing required column %q in CSV header", spec.Name)
+			}
+			continue
+		}
+
+		*field = position
+	}
+
+	return columns, nil
+}
+
+// Function "ReadCustomersWithSchema" reads customers from CSV file with columns described by "schema",
+// see "ReadOptions.Schema" to combine it with other options.
+func ReadCustomersWithSchema(r io.Reader, schema Schema) ([]customer, error) {
+	return ReadCustomersFromCSVWithOptions(r, ReadOptions{Schema: &schema})
+}
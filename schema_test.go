@@ -0,0 +1,168 @@
+package customerimporter
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCustomersWithSchema(t *testing.T) {
+	fullSchema := Schema{Columns: []ColumnSpec{
+		{Name: "given_name", Field: FIELD_FIRST_NAME, Required: true},
+		{Name: "second_name", Field: FIELD_MIDDLE_NAME},
+		{Name: "family_name", Field: FIELD_LAST_NAME, Required: true},
+		{Name: "mail", Field: FIELD_EMAIL, Required: true},
+		{Name: "sex", Field: FIELD_GENDER},
+		{Name: "ip", Field: FIELD_IP_ADDRESS, Required: true},
+	}}
+	contactSchema := Schema{Columns: []ColumnSpec{
+		{Name: "Contact", Field: FIELD_EMAIL, Required: true},
+		{Name: "Name", Field: FIELD_FIRST_NAME},
+	}}
+
+	tests := []struct {
+		name    string
+		input   string
+		schema  Schema
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name: "Reordered columns with optional column missing",
+			input: `ip,Mail,family_name,given_name,signup_date
+192.168.1.1,first.last@example.com,Last,First,2024-01-01`,
+			schema: fullSchema,
+			want: []customer{
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: unknown, IPAddress: net.ParseIP("192.168.1.1").To4()},
+			},
+			wantErr: false,
+		},
+		{
+			name: "All columns present",
+			input: `given_name,second_name,family_name,mail,sex,ip
+First,Middle,Last,first.last@example.com,female,2001:db8::1`,
+			schema: fullSchema,
+			want: []customer{
+				{FirstName: "First", MiddleName: "Middle", LastName: "Last", Email: "first.last@example.com", Gender: female, IPAddress: net.ParseIP("2001:db8::1")},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Omitted fields left empty",
+			input: `id,contact
+1,first.last@example.com
+2,second.last@example.org`,
+			schema: contactSchema,
+			want: []customer{
+				{Email: "first.last@example.com"},
+				{Email: "second.last@example.org"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Present column still validated",
+			input: `given_name,family_name,mail,ip
+First,Last,not-an-email,192.168.1.1`,
+			schema:  fullSchema,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Duplicate column reads the first one",
+			input: `given_name,family_name,mail,ip,mail
+First,Last,first.last@example.com,192.168.1.1,other.last@example.com`,
+			schema: fullSchema,
+			want: []customer{
+				{FirstName: "First", LastName: "Last", Email: "first.last@example.com", Gender: unknown, IPAddress: net.ParseIP("192.168.1.1").To4()},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Missing required column",
+			input: `given_name,family_name,ip
+First,Last,192.168.1.1`,
+			schema:  fullSchema,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Unknown field",
+			input: `mail
+first.last@example.com`,
+			schema:  Schema{Columns: []ColumnSpec{{Name: "mail", Field: "phone"}}},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Field mapped twice",
+			input: `mail,email
+first.last@example.com,first.last@example.com`,
+			schema:  Schema{Columns: []ColumnSpec{{Name: "mail", Field: FIELD_EMAIL}, {Name: "email", Field: FIELD_EMAIL}}},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCustomersWithSchema(strings.NewReader(tt.input), tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCustomersWithSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCustomersWithSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaWithCountOnly(t *testing.T) {
+	input := `id,contact
+1,first.last@example.com
+2,second.last@example.com`
+	schema := Schema{Columns: []ColumnSpec{{Name: "contact", Field: FIELD_EMAIL, Required: true}}}
+
+	for _, countOnly := range []bool{false, true} {
+		got, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{Schema: &schema, CountOnly: countOnly})
+		if err != nil {
+			t.Fatalf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly=%v unexpected error: %v", countOnly, err)
+		}
+
+		want := []domainCount{{Domain: "example.com", Count: 2}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly=%v = %v, want %v", countOnly, got, want)
+		}
+	}
+}
+
+func TestSchemaWithoutEmailCounting(t *testing.T) {
+	input := `first_name,contact
+First,first.last@example.com
+Second,second.last@example.com`
+
+	tests := []struct {
+		name   string
+		schema Schema
+	}{
+		{name: "No email column in schema", schema: Schema{Columns: []ColumnSpec{{Name: "first_name", Field: FIELD_FIRST_NAME, Required: true}}}},
+		{name: "Optional email column missing", schema: Schema{Columns: []ColumnSpec{{Name: "mail", Field: FIELD_EMAIL}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, countOnly := range []bool{false, true} {
+				_, err := ReadAndCountDomainsFromCSVWithOptions(strings.NewReader(input), ReadOptions{Schema: &tt.schema, CountOnly: countOnly})
+				if !errors.Is(err, ErrNoEmailColumn) {
+					t.Errorf("ReadAndCountDomainsFromCSVWithOptions() with CountOnly=%v error = %v, want %v", countOnly, err, ErrNoEmailColumn)
+				}
+			}
+
+			got, err := ReadCustomersFromCSVWithOptions(strings.NewReader(input), ReadOptions{Schema: &tt.schema})
+			if err != nil || len(got) != 2 {
+				t.Errorf("ReadCustomersFromCSVWithOptions() = %v, %v, want 2 customers", got, err)
+			}
+		})
+	}
+}
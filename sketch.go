@@ -0,0 +1,241 @@
+package customerimporter
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Const "APPROX_HEAVY_HITTERS" signifies the number of most frequent domains tracked by "ApproxCountDomains".
+const APPROX_HEAVY_HITTERS = 100
+
+// Type "countMinSketch" estimates counts of strings in fixed memory of "width" x "depth" counters.
+// Estimates are never lower than true counts, hash collisions can only make them higher.
+type countMinSketch struct {
+	width    uint64
+	counters [][]int
+}
+
+// Function "newCountMinSketch" creates an empty sketch with "depth" rows of "width" counters.
+func newCountMinSketch(width, depth int) *countMinSketch {
+	counters := make([][]int, depth)
+	for i := range counters {
+		counters[i] = make([]int, width)
+	}
+
+	return &countMinSketch{width: uint64(width), counters: counters}
+}
+
+// Method "add" counts one occurrence of "value" and returns its updated estimate.
+func (cms *countMinSketch) add(value string) int {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	sum := hash.Sum64()
+
+	// row positions are derived from two halves of a single hash, see Kirsch and Mitzenmacher "Less Hashing, Same Performance"
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	estimate := 0
+	for i, row := range cms.counters {
+		position := (h1 + uint64(i)*h2) % cms.width
+		row[position]++
+		if i == 0 || row[position] < estimate {
+			estimate = row[position]
+		}
+	}
+
+	return estimate
+}
+
+// Type "heavyHitters" is a min-heap of domain counts, keeping the least frequent tracked domain on top.
+type heavyHitters struct {
+	counts    []domainCount
+	positions map[string]int
+}
+
+func (hh *heavyHitters) Len() int { return len(hh.counts) }
+
+func (hh *heavyHitters) Less(i, j int) bool {
+	if hh.counts[i].Count != hh.counts[j].Count {
+		return hh.counts[i].Count < hh.counts[j].Count
+	}
+	return hh.counts[i].Domain > hh.counts[j].Domain
+}
+
+func (hh *heavyHitters) Swap(i, j int) {
+	hh.counts[i], hh.counts[j] = hh.counts[j], hh.counts[i]
+	hh.positions[hh.counts[i].Domain] = i
+	hh.positions[hh.counts[j].Domain] = j
+}
+
+func (hh *heavyHitters) Push(x any) {
+	dc := x.(domainCount)
+	hh.positions[dc.Domain] = len(hh.counts)
+	hh.counts = append(hh.counts, dc)
+}
+
+func (hh *heavyHitters) Pop() any {
+	last := hh.counts[len(hh.counts)-1]
+	hh.counts = hh.counts[:len(hh.counts)-1]
+	delete(hh.positions, last.Domain)
+	return last
+}
+
+// Method "offer" records the latest estimate of a domain, tracking it if it is among "limit" most frequent ones.
+func (hh *heavyHitters) offer(domain string, estimate, limit int) {
+	if i, ok := hh.positions[domain]; ok {
+		hh.counts[i].Count = estimate
+		heap.Fix(hh, i)
+		return
+	}
+
+	if hh.Len() < limit {
+		heap.Push(hh, domainCount{Domain: domain, Count: estimate})
+		return
+	}
+
+	if estimate > hh.counts[0].Count {
+		delete(hh.positions, hh.counts[0].Domain)
+		hh.counts[0] = domainCount{Domain: domain, Count: estimate}
+		hh.positions[domain] = 0
+		heap.Fix(hh, 0)
+	}
+}
+
+// Function "ApproxCountDomains" reads CSV file like "ReadAndCountDomainsFromCSV" and returns approximate counts
+// of up to "APPROX_HEAVY_HITTERS" most frequent domains, using memory bounded by "width" x "depth" counters instead of a map of all domains.
+//
+// Counts are never underestimated. With N valid lines, each count exceeds the exact one by at most e/width*N
+// with probability 1-e^-depth, so wider sketches are more accurate and deeper ones fail less often.
+// Domains with counts close to the least frequent returned one may be missing or ranked differently than exact counts would.
+func ApproxCountDomains(r io.Reader, width, depth int) ([]domainCount, error) {
+	if width <= 0 || depth <= 0 {
+		return nil, fmt.Errorf("invalid sketch size %dx%d", width, depth)
+	}
+
+	sketch := newCountMinSketch(width, depth)
+	hitters := &heavyHitters{positions: map[string]int{}}
+
+	err := processCustomersFromCSV(r, ReadOptions{}, func(customer customer) error {
+		domain := customer.GetDomain()
+		hitters.offer(domain, sketch.add(domain), APPROX_HEAVY_HITTERS)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := append([]domainCount{}, hitters.counts...)
+	SortDomainCountsFunc(counts, byCountDescending)
+
+	return counts, nil
+}
+
+// Consts "MIN_HLL_PRECISION" and "MAX_HLL_PRECISION" signify the range of precision accepted by "EstimateUniqueDomains".
+const (
+	MIN_HLL_PRECISION = 4
+	MAX_HLL_PRECISION = 18
+)
+
+// Type "hyperLogLog" estimates the number of distinct strings in fixed memory of 2^precision one-byte registers.
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// Function "newHyperLogLog" creates an empty HyperLogLog counter with 2^precision registers.
+func newHyperLogLog(precision int) *hyperLogLog {
+	return &hyperLogLog{precision: uint8(precision), registers: make([]uint8, 1<<precision)}
+}
+
+// Function "hashString" hashes "value" to 64 bits with FNV-1a, mixed with MurmurHash3 finalizer,
+// as HyperLogLog needs all bits to be evenly distributed and FNV alone leaves similar strings with similar high bits.
+func hashString(value string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	sum := hash.Sum64()
+
+	sum ^= sum >> 33
+	sum *= 0xff51afd7ed558ccd
+	sum ^= sum >> 33
+	sum *= 0xc4ceb9fe1a85ec53
+	sum ^= sum >> 33
+
+	return sum
+}
+
+// Method "add" records one occurrence of "value".
+func (hll *hyperLogLog) add(value string) {
+	sum := hashString(value)
+
+	// the first bits pick the register, the position of the first set bit among the rest is the observed rank
+	register := sum >> (64 - hll.precision)
+	rank := uint8(min(bits.LeadingZeros64(sum<<hll.precision), 64-int(hll.precision))) + 1
+
+	if rank > hll.registers[register] {
+		hll.registers[register] = rank
+	}
+}
+
+// Method "estimate" returns the estimated number of distinct values added, see Flajolet et al. "HyperLogLog: the analysis
+// of a near-optimal cardinality estimation algorithm". Small cardinalities are estimated with linear counting instead.
+func (hll *hyperLogLog) estimate() uint64 {
+	m := float64(len(hll.registers))
+
+	var alpha float64
+	switch len(hll.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	sum := 0.0
+	zeros := 0
+	for _, rank := range hll.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// Function "EstimateUniqueDomains" reads CSV file like "ReadAndCountDomainsFromCSV" and returns an estimated number of unique domains,
+// using a HyperLogLog counter of 2^precision bytes instead of a map of all domains. Precision must be between "MIN_HLL_PRECISION"
+// and "MAX_HLL_PRECISION".
+//
+// The standard error of the estimate is 1.04/sqrt(2^precision), e.g. 0.8% for precision 14 using 16 KiB, so about 95% of
+// estimates are within twice that of the exact number. Small numbers of domains, up to a few times 2^precision, are usually
+// estimated more accurately.
+func EstimateUniqueDomains(r io.Reader, precision int) (uint64, error) {
+	if precision < MIN_HLL_PRECISION || precision > MAX_HLL_PRECISION {
+		return 0, fmt.Errorf("invalid precision %d, want between %d and %d", precision, MIN_HLL_PRECISION, MAX_HLL_PRECISION)
+	}
+
+	hll := newHyperLogLog(precision)
+	opts := ReadOptions{}
+
+	err := processParsedLines(r, opts, true, parseEmailLine, func(email email) error {
+		hll.add(email.extractDomainWithOptions(opts))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return hll.estimate(), nil
+}
@@ -0,0 +1,141 @@
+package customerimporter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestApproxCountDomains(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("first_name,last_name,email,gender,ip_address\n")
+
+	lines := 0
+	exact := map[string]int{}
+	addLine := func(domain string) {
+		fmt.Fprintf(&sb, "First,Last,user%d@%s,male,10.0.0.1\n", lines, domain)
+		exact[domain]++
+		lines++
+	}
+
+	// 20 frequent domains with distinct counts, followed by a long tail of domains seen once
+	for i := 0; i < 20; i++ {
+		for j := 0; j < (20-i)*10; j++ {
+			addLine(fmt.Sprintf("frequent%d.com", i))
+		}
+	}
+	for i := 0; i < 500; i++ {
+		addLine(fmt.Sprintf("rare%d.com", i))
+	}
+
+	width, depth := 1000, 5
+	tolerance := int(math.Ceil(math.E / float64(width) * float64(lines)))
+
+	got, err := ApproxCountDomains(strings.NewReader(sb.String()), width, depth)
+	if err != nil {
+		t.Fatalf("ApproxCountDomains() error = %v", err)
+	}
+
+	if len(got) > APPROX_HEAVY_HITTERS {
+		t.Errorf("ApproxCountDomains() returned %d domains, want at most %d", len(got), APPROX_HEAVY_HITTERS)
+	}
+
+	for _, dc := range got {
+		if dc.Count < exact[dc.Domain] || dc.Count > exact[dc.Domain]+tolerance {
+			t.Errorf("ApproxCountDomains() count of %s = %d, want within [%d, %d]", dc.Domain, dc.Count, exact[dc.Domain], exact[dc.Domain]+tolerance)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		if got[i].Domain != fmt.Sprintf("frequent%d.com", i) {
+			t.Errorf("ApproxCountDomains() domain %d = %s, want frequent%d.com", i, got[i].Domain, i)
+		}
+	}
+}
+
+func TestApproxCountDomainsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		width int
+		depth int
+	}{
+		{
+			name:  "Zero width",
+			input: generateCSV(10, 2),
+			width: 0,
+			depth: 4,
+		},
+		{
+			name:  "Negative depth",
+			input: generateCSV(10, 2),
+			width: 100,
+			depth: -1,
+		},
+		{
+			name:  "Invalid email",
+			input: "first_name,last_name,email,gender,ip_address\nFirst,Last,not-an-email,male,10.0.0.1\n",
+			width: 100,
+			depth: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApproxCountDomains(strings.NewReader(tt.input), tt.width, tt.depth); err == nil {
+				t.Errorf("ApproxCountDomains() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestEstimateUniqueDomains(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     int
+		domains   int
+		precision int
+		tolerance float64
+	}{
+		{name: "Few domains", lines: 1000, domains: 10, precision: 10, tolerance: 0.1},
+		{name: "Linear counting range", lines: 5000, domains: 1000, precision: 12, tolerance: 0.05},
+		{name: "Many domains", lines: 50000, domains: 40000, precision: 14, tolerance: 0.05},
+		{name: "Low precision", lines: 20000, domains: 20000, precision: MIN_HLL_PRECISION, tolerance: 1.04 / 4 * 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EstimateUniqueDomains(strings.NewReader(generateCSV(tt.lines, tt.domains)), tt.precision)
+			if err != nil {
+				t.Fatalf("EstimateUniqueDomains() error = %v", err)
+			}
+
+			if relativeErr := math.Abs(float64(got)-float64(tt.domains)) / float64(tt.domains); relativeErr > tt.tolerance {
+				t.Errorf("EstimateUniqueDomains() = %d, want %d within %.0f%%", got, tt.domains, 100*tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestEstimateUniqueDomainsEmpty(t *testing.T) {
+	got, err := EstimateUniqueDomains(strings.NewReader("first_name,last_name,email,gender,ip_address\n"), 14)
+	if err != nil {
+		t.Fatalf("EstimateUniqueDomains() error = %v", err)
+	}
+	if got != 0 {
+		t.Errorf("EstimateUniqueDomains() = %d, want 0", got)
+	}
+}
+
+func TestEstimateUniqueDomainsInvalid(t *testing.T) {
+	for _, precision := range []int{0, MIN_HLL_PRECISION - 1, MAX_HLL_PRECISION + 1} {
+		if _, err := EstimateUniqueDomains(strings.NewReader(generateCSV(10, 2)), precision); err == nil {
+			t.Errorf("EstimateUniqueDomains() with precision %d error = nil, want error", precision)
+		}
+	}
+
+	if _, err := EstimateUniqueDomains(strings.NewReader("first_name,last_name,email,gender,ip_address\nFirst,Last,bad,male,10.0.0.1\n"), 14); err == nil {
+		t.Errorf("EstimateUniqueDomains() with invalid line error = nil, want error")
+	}
+}
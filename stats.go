@@ -0,0 +1,411 @@
+package customerimporter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+// Const "DEFAULT_UNKNOWN_GENDER_LABEL" signifies the label "CountByGender" gives customers of "unknown" gender by default.
+const DEFAULT_UNKNOWN_GENDER_LABEL = "unknown"
+
+// Const "DEFAULT_OTHER_LABEL" signifies the domain name of the bucket "CollapseSmallDomains" sums small domains into by default.
+const DEFAULT_OTHER_LABEL = "other"
+
+// Function "IPFamilyBreakdown" counts how many customers have an IPv4, IPv6 or invalid (missing) IP address.
+// It reads IP addresses already parsed by "net.ParseIP" while reading the CSV file.
+func IPFamilyBreakdown(customers []customer) (v4, v6, invalid int) {
+	for _, c := range customers {
+		switch {
+		case c.IPAddress.To4() != nil:
+			v4++
+		case c.IPAddress.To16() != nil:
+			v6++
+		default:
+			invalid++
+		}
+	}
+
+	return v4, v6, invalid
+}
+
+// Variable "DefaultFreeProviders" lists popular free email providers, used by "ClassifyDomains" when no set is given.
+var DefaultFreeProviders = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+	"yahoo.com":      true,
+	"outlook.com":    true,
+	"hotmail.com":    true,
+	"live.com":       true,
+	"msn.com":        true,
+	"aol.com":        true,
+	"icloud.com":     true,
+	"me.com":         true,
+	"mail.com":       true,
+	"gmx.com":        true,
+	"gmx.net":        true,
+	"yandex.com":     true,
+	"proton.me":      true,
+	"protonmail.com": true,
+	"zoho.com":       true,
+}
+
+// Function "ClassifyDomains" splits domain counts into free email providers and corporate domains, preserving their order.
+// Domains found in "freeProviders" are free, all others are corporate. "DefaultFreeProviders" is used when it is nil.
+func ClassifyDomains(counts []domainCount, freeProviders map[string]bool) (free, corporate []domainCount) {
+	if freeProviders == nil {
+		freeProviders = DefaultFreeProviders
+	}
+
+	free, corporate = []domainCount{}, []domainCount{}
+	for _, dc := range counts {
+		if freeProviders[dc.Domain] {
+			free = append(free, dc)
+		} else {
+			corporate = append(corporate, dc)
+		}
+	}
+
+	return free, corporate
+}
+
+// Variable "DefaultDisposableDomains" lists well-known disposable email domains, used by "FlagDisposableDomains" when no set is given.
+var DefaultDisposableDomains = map[string]bool{
+	"mailinator.com":    true,
+	"10minutemail.com":  true,
+	"guerrillamail.com": true,
+	"sharklasers.com":   true,
+	"temp-mail.org":     true,
+	"tempmail.com":      true,
+	"throwawaymail.com": true,
+	"yopmail.com":       true,
+	"getnada.com":       true,
+	"trashmail.com":     true,
+	"dispostable.com":   true,
+	"maildrop.cc":       true,
+}
+
+// Function "FlagDisposableDomains" returns only domain counts of disposable email domains, preserving their order.
+// Domains are looked up in "disposableSet", "DefaultDisposableDomains" is used when it is nil.
+func FlagDisposableDomains(counts []domainCount, disposableSet map[string]bool) []domainCount {
+	if disposableSet == nil {
+		disposableSet = DefaultDisposableDomains
+	}
+
+	flagged := []domainCount{}
+	for _, dc := range counts {
+		if disposableSet[dc.Domain] {
+			flagged = append(flagged, dc)
+		}
+	}
+
+	return flagged
+}
+
+// Function "MissingFieldReport" counts, per field, how many customers have an empty value or "unknown" gender.
+// Keys are "FIELD_*" names, every field is reported even if no value is missing.
+func MissingFieldReport(customers []customer) map[string]int {
+	report := map[string]int{
+		FIELD_FIRST_NAME:  0,
+		FIELD_MIDDLE_NAME: 0,
+		FIELD_LAST_NAME:   0,
+		FIELD_EMAIL:       0,
+		FIELD_GENDER:      0,
+		FIELD_IP_ADDRESS:  0,
+		FIELD_COUNTRY:     0,
+	}
+
+	for _, c := range customers {
+		if c.FirstName == "" {
+			report[FIELD_FIRST_NAME]++
+		}
+		if c.MiddleName == "" {
+			report[FIELD_MIDDLE_NAME]++
+		}
+		if c.LastName == "" {
+			report[FIELD_LAST_NAME]++
+		}
+		if c.Email == "" {
+			report[FIELD_EMAIL]++
+		}
+		if c.Gender == unknown {
+			report[FIELD_GENDER]++
+		}
+		if c.IPAddress == nil {
+			report[FIELD_IP_ADDRESS]++
+		}
+		if c.Country == "" {
+			report[FIELD_COUNTRY]++
+		}
+	}
+
+	return report
+}
+
+// Function "DomainLengthExtremes" returns the shortest and the longest domain, measured in characters.
+// Domains of equal length are broken alphabetically, the first one wins. Both are empty for empty input.
+func DomainLengthExtremes(counts []domainCount) (shortest, longest string) {
+	for i, dc := range counts {
+		if i == 0 {
+			shortest, longest = dc.Domain, dc.Domain
+			continue
+		}
+
+		length := utf8.RuneCountInString(dc.Domain)
+
+		shortestLength := utf8.RuneCountInString(shortest)
+		if length < shortestLength || length == shortestLength && dc.Domain < shortest {
+			shortest = dc.Domain
+		}
+
+		longestLength := utf8.RuneCountInString(longest)
+		if length > longestLength || length == longestLength && dc.Domain < longest {
+			longest = dc.Domain
+		}
+	}
+
+	return shortest, longest
+}
+
+// Function "CollapseSmallDomains" keeps domain counts of at least "minCount" in their order and sums the rest into a single
+// bucket named "label" ("DEFAULT_OTHER_LABEL" when empty), appended at the end. No bucket is added when nothing is collapsed.
+func CollapseSmallDomains(counts []domainCount, minCount int, label string) []domainCount {
+	if label == "" {
+		label = DEFAULT_OTHER_LABEL
+	}
+
+	collapsed := []domainCount{}
+	other := domainCount{Domain: label}
+	hasOther := false
+
+	for _, dc := range counts {
+		if dc.Count >= minCount {
+			collapsed = append(collapsed, dc)
+			continue
+		}
+
+		other.Count += dc.Count
+		hasOther = true
+	}
+
+	if hasOther {
+		collapsed = append(collapsed, other)
+	}
+
+	return collapsed
+}
+
+// Type "TopDomainsResult" holds the most frequent domains returned by "TopDomains" along with the number of all unique domains,
+// e.g. to show "showing 10 of 4,312 domains".
+type TopDomainsResult struct {
+	Domains   []domainCount
+	Total     int
+	Truncated bool
+}
+
+// Function "TopDomains" returns up to "n" first domain counts, or all of them when "n" is not positive, expecting counts
+// sorted like "ReadAndCountDomainsFromCSV". "Truncated" is set when any domains were left out. The counts are copied.
+func TopDomains(counts []domainCount, n int) TopDomainsResult {
+	result := TopDomainsResult{Total: len(counts)}
+
+	if n > 0 && len(counts) > n {
+		counts = counts[:n]
+		result.Truncated = true
+	}
+	result.Domains = append([]domainCount{}, counts...)
+
+	return result
+}
+
+// Function "CountBySubnet" counts customers per IP subnet, masking IPv4 addresses to "v4Bits" and IPv6 addresses to "v6Bits"
+// prefix length, e.g. 24 and 48. Subnets are written in CIDR notation as "Domain" of returned counts, sorted like domains.
+// Prefix lengths are clamped to the valid range and customers without IP address are left out.
+func CountBySubnet(customers []customer, v4Bits, v6Bits int) []domainCount {
+	v4Mask := net.CIDRMask(min(max(v4Bits, 0), 8*net.IPv4len), 8*net.IPv4len)
+	v6Mask := net.CIDRMask(min(max(v6Bits, 0), 8*net.IPv6len), 8*net.IPv6len)
+
+	subnetCounts := map[string]int{}
+	for _, c := range customers {
+		if c.IPAddress == nil {
+			continue
+		}
+
+		subnet := net.IPNet{IP: c.IPAddress.Mask(v6Mask), Mask: v6Mask}
+		if ipv4 := c.IPAddress.To4(); ipv4 != nil {
+			subnet = net.IPNet{IP: ipv4.Mask(v4Mask), Mask: v4Mask}
+		}
+
+		subnetCounts[subnet.String()]++
+	}
+
+	return sortDomainCounts(subnetCounts)
+}
+
+// Function "FilterByCIDR" returns customers whose IP address is within "cidr" network, e.g. "192.168.1.0/24", preserving their order.
+// IPv4-mapped IPv6 addresses match IPv4 networks. Customers without IP address are left out. It returns an error for invalid "cidr".
+func FilterByCIDR(customers []customer, cidr string) ([]customer, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+
+	filtered := []customer{}
+	for _, c := range customers {
+		if c.IPAddress != nil && network.Contains(c.IPAddress) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Function "CountByCountry" counts customers per country code read from the country column, sorted like domains, with codes written
+// as "Domain" of returned counts. Customers without country are left out, their IP addresses are not geolocated.
+func CountByCountry(customers []customer) []domainCount {
+	countryCounts := map[string]int{}
+	for _, c := range customers {
+		if c.Country == "" {
+			continue
+		}
+
+		countryCounts[c.Country]++
+	}
+
+	return sortDomainCounts(countryCounts)
+}
+
+// Function "CountByGender" counts customers per gender, sorted like domains, with gender names written as "Domain" of returned counts.
+// Customers of "unknown" gender are labeled "unknownLabel", e.g. "not specified", or "DEFAULT_UNKNOWN_GENDER_LABEL" when it is empty.
+func CountByGender(customers []customer, unknownLabel string) []domainCount {
+	if unknownLabel == "" {
+		unknownLabel = DEFAULT_UNKNOWN_GENDER_LABEL
+	}
+
+	genderCounts := map[string]int{}
+	for _, c := range customers {
+		label := c.Gender.String()
+		if c.Gender == unknown {
+			label = unknownLabel
+		}
+
+		genderCounts[label]++
+	}
+
+	return sortDomainCounts(genderCounts)
+}
+
+// Function "CountBySecondLevelLabel" counts customers per primary label of their registrable domain, grouping domains of a brand
+// across public suffixes, e.g. "example.com", "mail.example.co.uk" and "example.org" are all counted as "example".
+// Domains without a registrable part, e.g. a bare public suffix, are counted under their full name.
+func CountBySecondLevelLabel(customers []customer) []domainCount {
+	labelCounts := map[string]int{}
+	for _, c := range customers {
+		label := c.GetDomain()
+		if registrable, err := c.RegistrableDomain(); err == nil {
+			label, _, _ = strings.Cut(registrable, ".")
+		}
+
+		labelCounts[label]++
+	}
+
+	return sortDomainCounts(labelCounts)
+}
+
+// Function "TopFirstNamePerDomain" returns the most common first name of customers per email domain.
+// Names are compared exactly, ties are broken alphabetically. Customers without first name are left out.
+func TopFirstNamePerDomain(customers []customer) map[string]string {
+	nameCounts := map[string]map[string]int{}
+	for _, c := range customers {
+		if c.FirstName == "" {
+			continue
+		}
+
+		domain := c.GetDomain()
+		if nameCounts[domain] == nil {
+			nameCounts[domain] = map[string]int{}
+		}
+		nameCounts[domain][c.FirstName]++
+	}
+
+	topNames := make(map[string]string, len(nameCounts))
+	for domain, counts := range nameCounts {
+		topNames[domain] = sortDomainCounts(counts)[0].Domain
+	}
+
+	return topNames
+}
+
+// Function "CountByDomainAndGender" counts customers per email domain and gender, e.g. for a cross-tab report.
+// Only genders present in a domain are listed, "unknown" included.
+func CountByDomainAndGender(customers []customer) map[string]map[gender]int {
+	counts := map[string]map[gender]int{}
+	for _, c := range customers {
+		domain := c.GetDomain()
+		if counts[domain] == nil {
+			counts[domain] = map[gender]int{}
+		}
+		counts[domain][c.Gender]++
+	}
+
+	return counts
+}
+
+// Function "DominantGenderPerDomain" returns the most common gender of customers per email domain.
+// Customers of "unknown" gender do not vote, but their domains are listed. When no gender has the most votes,
+// because of a tie or no votes at all, the domain is marked "unknown" rather than favoring either gender.
+func DominantGenderPerDomain(customers []customer) map[string]gender {
+	genderCounts := map[string]map[gender]int{}
+	for _, c := range customers {
+		domain := c.GetDomain()
+		if genderCounts[domain] == nil {
+			genderCounts[domain] = map[gender]int{}
+		}
+		if c.Gender != unknown {
+			genderCounts[domain][c.Gender]++
+		}
+	}
+
+	dominant := make(map[string]gender, len(genderCounts))
+	for domain, counts := range genderCounts {
+		top, topCount, tied := unknown, 0, false
+		for g, count := range counts {
+			switch {
+			case count > topCount:
+				top, topCount, tied = g, count, false
+			case count == topCount:
+				tied = true
+			}
+		}
+
+		if tied {
+			top = unknown
+		}
+		dominant[domain] = top
+	}
+
+	return dominant
+}
+
+// Function "CumulativeDomainShare" returns the running fraction of all customers covered by domains up to and including
+// each one, aligned to "counts", e.g. for "top N domains cover X% of customers" analysis. Counts are expected sorted,
+// as returned by counting functions. The last value is 1, unless there are no customers at all.
+func CumulativeDomainShare(counts []domainCount) []float64 {
+	total := 0
+	for _, dc := range counts {
+		total += dc.Count
+	}
+
+	shares := make([]float64, len(counts))
+	if total == 0 {
+		return shares
+	}
+
+	covered := 0
+	for i, dc := range counts {
+		covered += dc.Count
+		shares[i] = float64(covered) / float64(total)
+	}
+
+	return shares
+}
@@ -0,0 +1,689 @@
+package customerimporter
+
+import (
+	"math"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIPFamilyBreakdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		customers   []customer
+		wantV4      int
+		wantV6      int
+		wantInvalid int
+	}{
+		{
+			name: "Mixed dataset",
+			customers: []customer{
+				{IPAddress: net.ParseIP("192.168.1.1")},
+				{IPAddress: net.ParseIP("10.0.0.1")},
+				{IPAddress: net.ParseIP("2001:db8::1")},
+				{IPAddress: net.ParseIP("::ffff:192.168.1.2")},
+				{IPAddress: nil},
+			},
+			wantV4:      3,
+			wantV6:      1,
+			wantInvalid: 1,
+		},
+		{
+			name: "All IPv6",
+			customers: []customer{
+				{IPAddress: net.ParseIP("2001:db8::1")},
+				{IPAddress: net.ParseIP("fe80::1")},
+			},
+			wantV6: 2,
+		},
+		{
+			name:      "No customers",
+			customers: []customer{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v4, v6, invalid := IPFamilyBreakdown(tt.customers)
+			if v4 != tt.wantV4 || v6 != tt.wantV6 || invalid != tt.wantInvalid {
+				t.Errorf("IPFamilyBreakdown() = (%d, %d, %d), want (%d, %d, %d)", v4, v6, invalid, tt.wantV4, tt.wantV6, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestClassifyDomains(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "gmail.com", Count: 10},
+		{Domain: "acme.com", Count: 5},
+		{Domain: "yahoo.com", Count: 3},
+		{Domain: "example.org", Count: 1},
+	}
+
+	tests := []struct {
+		name          string
+		freeProviders map[string]bool
+		wantFree      []domainCount
+		wantCorporate []domainCount
+	}{
+		{
+			name:          "Default free providers",
+			freeProviders: nil,
+			wantFree:      []domainCount{{Domain: "gmail.com", Count: 10}, {Domain: "yahoo.com", Count: 3}},
+			wantCorporate: []domainCount{{Domain: "acme.com", Count: 5}, {Domain: "example.org", Count: 1}},
+		},
+		{
+			name:          "Custom free providers",
+			freeProviders: map[string]bool{"example.org": true},
+			wantFree:      []domainCount{{Domain: "example.org", Count: 1}},
+			wantCorporate: []domainCount{{Domain: "gmail.com", Count: 10}, {Domain: "acme.com", Count: 5}, {Domain: "yahoo.com", Count: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			free, corporate := ClassifyDomains(counts, tt.freeProviders)
+			if !reflect.DeepEqual(free, tt.wantFree) {
+				t.Errorf("ClassifyDomains() free = %v, want %v", free, tt.wantFree)
+			}
+			if !reflect.DeepEqual(corporate, tt.wantCorporate) {
+				t.Errorf("ClassifyDomains() corporate = %v, want %v", corporate, tt.wantCorporate)
+			}
+		})
+	}
+}
+
+func TestFlagDisposableDomains(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "gmail.com", Count: 10},
+		{Domain: "mailinator.com", Count: 4},
+		{Domain: "acme.com", Count: 2},
+		{Domain: "10minutemail.com", Count: 1},
+	}
+
+	tests := []struct {
+		name          string
+		disposableSet map[string]bool
+		want          []domainCount
+	}{
+		{
+			name:          "Default disposable domains",
+			disposableSet: nil,
+			want:          []domainCount{{Domain: "mailinator.com", Count: 4}, {Domain: "10minutemail.com", Count: 1}},
+		},
+		{
+			name:          "Custom disposable domains",
+			disposableSet: map[string]bool{"acme.com": true},
+			want:          []domainCount{{Domain: "acme.com", Count: 2}},
+		},
+		{
+			name:          "No disposable domains",
+			disposableSet: map[string]bool{},
+			want:          []domainCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FlagDisposableDomains(counts, tt.disposableSet); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlagDisposableDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingFieldReport(t *testing.T) {
+	customers := []customer{
+		{FirstName: "First", MiddleName: "Middle", LastName: "Last", Email: "first.last@example.com", Gender: male, IPAddress: net.ParseIP("192.168.1.1"), Country: "PL"},
+		{FirstName: "First", LastName: "Last", Email: "second.last@example.com", Gender: unknown, IPAddress: net.ParseIP("192.168.1.2")},
+		{FirstName: "", LastName: "Last", Email: "", Gender: female},
+	}
+
+	want := map[string]int{
+		FIELD_FIRST_NAME:  1,
+		FIELD_MIDDLE_NAME: 2,
+		FIELD_LAST_NAME:   0,
+		FIELD_EMAIL:       1,
+		FIELD_GENDER:      1,
+		FIELD_IP_ADDRESS:  1,
+		FIELD_COUNTRY:     2,
+	}
+
+	got := MissingFieldReport(customers)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingFieldReport() = %v, want %v", got, want)
+	}
+}
+
+func TestDomainLengthExtremes(t *testing.T) {
+	tests := []struct {
+		name         string
+		counts       []domainCount
+		wantShortest string
+		wantLongest  string
+	}{
+		{
+			name: "Varying lengths",
+			counts: []domainCount{
+				{Domain: "example.com", Count: 3},
+				{Domain: "a.io", Count: 2},
+				{Domain: "subdomain.example.org", Count: 1},
+				{Domain: "foo.org", Count: 1},
+			},
+			wantShortest: "a.io",
+			wantLongest:  "subdomain.example.org",
+		},
+		{
+			name: "Ties broken alphabetically",
+			counts: []domainCount{
+				{Domain: "zz.io", Count: 3},
+				{Domain: "aa.io", Count: 2},
+				{Domain: "example.com", Count: 1},
+				{Domain: "abcdefg.com", Count: 1},
+			},
+			wantShortest: "aa.io",
+			wantLongest:  "abcdefg.com",
+		},
+		{
+			name:         "Single domain",
+			counts:       []domainCount{{Domain: "example.com", Count: 1}},
+			wantShortest: "example.com",
+			wantLongest:  "example.com",
+		},
+		{
+			name:         "Empty input",
+			counts:       nil,
+			wantShortest: "",
+			wantLongest:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortest, longest := DomainLengthExtremes(tt.counts)
+			if shortest != tt.wantShortest || longest != tt.wantLongest {
+				t.Errorf("DomainLengthExtremes() = (%q, %q), want (%q, %q)", shortest, longest, tt.wantShortest, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func TestCollapseSmallDomains(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "gmail.com", Count: 50},
+		{Domain: "example.com", Count: 10},
+		{Domain: "foo.org", Count: 3},
+		{Domain: "bar.org", Count: 2},
+		{Domain: "baz.org", Count: 1},
+	}
+
+	tests := []struct {
+		name     string
+		counts   []domainCount
+		minCount int
+		label    string
+		want     []domainCount
+	}{
+		{
+			name:     "Small domains collapsed",
+			counts:   counts,
+			minCount: 5,
+			label:    "",
+			want: []domainCount{
+				{Domain: "gmail.com", Count: 50},
+				{Domain: "example.com", Count: 10},
+				{Domain: "other", Count: 6},
+			},
+		},
+		{
+			name:     "Custom label",
+			counts:   counts,
+			minCount: 20,
+			label:    "everyone else",
+			want: []domainCount{
+				{Domain: "gmail.com", Count: 50},
+				{Domain: "everyone else", Count: 16},
+			},
+		},
+		{
+			name:     "Nothing collapsed",
+			counts:   counts,
+			minCount: 1,
+			label:    "",
+			want:     counts,
+		},
+		{
+			name:     "Empty input",
+			counts:   nil,
+			minCount: 5,
+			label:    "",
+			want:     []domainCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseSmallDomains(tt.counts, tt.minCount, tt.label); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CollapseSmallDomains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopDomains(t *testing.T) {
+	counts := []domainCount{
+		{Domain: "example1.com", Count: 5},
+		{Domain: "example2.com", Count: 3},
+		{Domain: "example3.com", Count: 2},
+		{Domain: "example4.com", Count: 1},
+	}
+
+	tests := []struct {
+		name   string
+		counts []domainCount
+		n      int
+		want   TopDomainsResult
+	}{
+		{
+			name:   "Truncated",
+			counts: counts,
+			n:      2,
+			want:   TopDomainsResult{Domains: counts[:2], Total: 4, Truncated: true},
+		},
+		{
+			name:   "Exactly n domains",
+			counts: counts,
+			n:      4,
+			want:   TopDomainsResult{Domains: counts, Total: 4, Truncated: false},
+		},
+		{
+			name:   "Fewer than n domains",
+			counts: counts,
+			n:      10,
+			want:   TopDomainsResult{Domains: counts, Total: 4, Truncated: false},
+		},
+		{
+			name:   "No limit",
+			counts: counts,
+			n:      0,
+			want:   TopDomainsResult{Domains: counts, Total: 4, Truncated: false},
+		},
+		{
+			name:   "No domains",
+			counts: nil,
+			n:      10,
+			want:   TopDomainsResult{Domains: []domainCount{}, Total: 0, Truncated: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopDomains(tt.counts, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopDomains() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountBySubnet(t *testing.T) {
+	customers := []customer{
+		{IPAddress: net.ParseIP("192.168.1.10").To4()},
+		{IPAddress: net.ParseIP("192.168.1.20").To4()},
+		{IPAddress: net.ParseIP("::ffff:192.168.1.30")},
+		{IPAddress: net.ParseIP("192.168.2.1").To4()},
+		{IPAddress: net.ParseIP("2001:db8:1234:1::1")},
+		{IPAddress: net.ParseIP("2001:db8:1234:2::1")},
+		{IPAddress: nil},
+	}
+
+	tests := []struct {
+		name   string
+		v4Bits int
+		v6Bits int
+		want   []domainCount
+	}{
+		{
+			name:   "IPv4 /24 and IPv6 /48",
+			v4Bits: 24,
+			v6Bits: 48,
+			want: []domainCount{
+				{Domain: "192.168.1.0/24", Count: 3},
+				{Domain: "2001:db8:1234::/48", Count: 2},
+				{Domain: "192.168.2.0/24", Count: 1},
+			},
+		},
+		{
+			name:   "IPv4 /16 and IPv6 /64",
+			v4Bits: 16,
+			v6Bits: 64,
+			want: []domainCount{
+				{Domain: "192.168.0.0/16", Count: 4},
+				{Domain: "2001:db8:1234:1::/64", Count: 1},
+				{Domain: "2001:db8:1234:2::/64", Count: 1},
+			},
+		},
+		{
+			name:   "Out of range prefixes clamped",
+			v4Bits: 40,
+			v6Bits: -8,
+			want: []domainCount{
+				{Domain: "::/0", Count: 2},
+				{Domain: "192.168.1.10/32", Count: 1},
+				{Domain: "192.168.1.20/32", Count: 1},
+				{Domain: "192.168.1.30/32", Count: 1},
+				{Domain: "192.168.2.1/32", Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountBySubnet(customers, tt.v4Bits, tt.v6Bits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountBySubnet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByCIDR(t *testing.T) {
+	inside := customer{Email: "inside@example.com", IPAddress: net.ParseIP("192.168.1.10").To4()}
+	mapped := customer{Email: "mapped@example.com", IPAddress: net.ParseIP("::ffff:192.168.1.20")}
+	outside := customer{Email: "outside@example.com", IPAddress: net.ParseIP("192.168.2.1").To4()}
+	ipv6 := customer{Email: "ipv6@example.com", IPAddress: net.ParseIP("2001:db8::1")}
+	noIP := customer{Email: "noip@example.com", IPAddress: nil}
+	customers := []customer{inside, outside, mapped, ipv6, noIP}
+
+	tests := []struct {
+		name    string
+		cidr    string
+		want    []customer
+		wantErr bool
+	}{
+		{
+			name:    "IPv4 /24",
+			cidr:    "192.168.1.0/24",
+			want:    []customer{inside, mapped},
+			wantErr: false,
+		},
+		{
+			name:    "IPv4 host bits ignored",
+			cidr:    "192.168.2.77/24",
+			want:    []customer{outside},
+			wantErr: false,
+		},
+		{
+			name:    "IPv6 /32",
+			cidr:    "2001:db8::/32",
+			want:    []customer{ipv6},
+			wantErr: false,
+		},
+		{
+			name:    "No matches",
+			cidr:    "10.0.0.0/8",
+			want:    []customer{},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid CIDR",
+			cidr:    "192.168.1.0",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilterByCIDR(customers, tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FilterByCIDR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterByCIDR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountByCountry(t *testing.T) {
+	customers := []customer{
+		{Country: "PL"},
+		{Country: "DE"},
+		{Country: "PL"},
+		{Country: ""},
+		{Country: "GB"},
+	}
+
+	want := []domainCount{
+		{Domain: "PL", Count: 2},
+		{Domain: "DE", Count: 1},
+		{Domain: "GB", Count: 1},
+	}
+
+	if got := CountByCountry(customers); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountByCountry() = %v, want %v", got, want)
+	}
+}
+
+func TestCountByGender(t *testing.T) {
+	customers := []customer{
+		{Gender: female},
+		{Gender: male},
+		{Gender: female},
+		{Gender: unknown},
+		{Gender: transgender},
+		{Gender: unknown},
+		{Gender: female},
+	}
+
+	tests := []struct {
+		name         string
+		unknownLabel string
+		want         []domainCount
+	}{
+		{
+			name:         "Custom unknown label",
+			unknownLabel: "not specified",
+			want: []domainCount{
+				{Domain: "female", Count: 3},
+				{Domain: "not specified", Count: 2},
+				{Domain: "male", Count: 1},
+				{Domain: "transgender", Count: 1},
+			},
+		},
+		{
+			name:         "Default unknown label",
+			unknownLabel: "",
+			want: []domainCount{
+				{Domain: "female", Count: 3},
+				{Domain: "unknown", Count: 2},
+				{Domain: "male", Count: 1},
+				{Domain: "transgender", Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountByGender(customers, tt.unknownLabel); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountByGender() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountBySecondLevelLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		emails []email
+		want   []domainCount
+	}{
+		{
+			name:   "Same label across suffixes",
+			emails: []email{"john@example.com", "jane@example.co.uk", "joe@example.org", "ann@other.com"},
+			want:   []domainCount{{Domain: "example", Count: 3}, {Domain: "other", Count: 1}},
+		},
+		{
+			name:   "Subdomains grouped",
+			emails: []email{"john@mail.example.co.uk", "jane@Example.com"},
+			want:   []domainCount{{Domain: "example", Count: 2}},
+		},
+		{
+			name:   "Public suffix counted as is",
+			emails: []email{"john@co.uk"},
+			want:   []domainCount{{Domain: "co.uk", Count: 1}},
+		},
+		{
+			name:   "No customers",
+			emails: nil,
+			want:   []domainCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customers := make([]customer, len(tt.emails))
+			for i, e := range tt.emails {
+				customers[i] = customer{Email: e}
+			}
+
+			if got := CountBySecondLevelLabel(customers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CountBySecondLevelLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopFirstNamePerDomain(t *testing.T) {
+	tests := []struct {
+		name      string
+		customers []customer
+		want      map[string]string
+	}{
+		{
+			name: "Most common name per domain",
+			customers: []customer{
+				{FirstName: "Anna", Email: "anna@example.com"},
+				{FirstName: "Jan", Email: "jan@example.com"},
+				{FirstName: "Jan", Email: "jan.k@Example.com"},
+				{FirstName: "Zoe", Email: "zoe@foo.org"},
+				{FirstName: "Adam", Email: "adam@foo.org"},
+				{FirstName: "", Email: "anonymous@bar.net"},
+			},
+			want: map[string]string{
+				"example.com": "Jan",
+				"foo.org":     "Adam",
+			},
+		},
+		{
+			name:      "No customers",
+			customers: nil,
+			want:      map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopFirstNamePerDomain(tt.customers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopFirstNamePerDomain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountByDomainAndGender(t *testing.T) {
+	customers := []customer{
+		{Email: "a@example1.com", Gender: male},
+		{Email: "b@example1.com", Gender: female},
+		{Email: "c@Example1.com", Gender: male},
+		{Email: "d@example1.com", Gender: unknown},
+		{Email: "a@example2.com", Gender: female},
+		{Email: "b@example2.com", Gender: transgender},
+	}
+
+	want := map[string]map[gender]int{
+		"example1.com": {male: 2, female: 1, unknown: 1},
+		"example2.com": {female: 1, transgender: 1},
+	}
+
+	if got := CountByDomainAndGender(customers); !reflect.DeepEqual(got, want) {
+		t.Errorf("CountByDomainAndGender() = %v, want %v", got, want)
+	}
+
+	if got := CountByDomainAndGender(nil); len(got) != 0 {
+		t.Errorf("CountByDomainAndGender() of no customers = %v, want empty", got)
+	}
+}
+
+func TestDominantGenderPerDomain(t *testing.T) {
+	customers := []customer{
+		{Email: "a@majority.com", Gender: male},
+		{Email: "b@majority.com", Gender: female},
+		{Email: "c@majority.com", Gender: male},
+		{Email: "a@tie.com", Gender: male},
+		{Email: "b@tie.com", Gender: female},
+		{Email: "c@tie.com", Gender: unknown},
+		{Email: "a@unknowns.com", Gender: unknown},
+		{Email: "b@unknowns.com", Gender: unknown},
+		{Email: "a@outvoted.com", Gender: unknown},
+		{Email: "b@outvoted.com", Gender: unknown},
+		{Email: "c@outvoted.com", Gender: transgender},
+	}
+
+	want := map[string]gender{
+		"majority.com": male,
+		"tie.com":      unknown,
+		"unknowns.com": unknown,
+		"outvoted.com": transgender,
+	}
+
+	if got := DominantGenderPerDomain(customers); !reflect.DeepEqual(got, want) {
+		t.Errorf("DominantGenderPerDomain() = %v, want %v", got, want)
+	}
+}
+
+func TestCumulativeDomainShare(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []domainCount
+		want   []float64
+	}{
+		{
+			name: "Sorted counts",
+			counts: []domainCount{
+				{Domain: "gmail.com", Count: 6},
+				{Domain: "example.com", Count: 2},
+				{Domain: "foo.org", Count: 1},
+				{Domain: "bar.org", Count: 1},
+			},
+			want: []float64{0.6, 0.8, 0.9, 1},
+		},
+		{
+			name:   "No customers",
+			counts: []domainCount{{Domain: "example.com", Count: 0}},
+			want:   []float64{0},
+		},
+		{
+			name:   "Empty input",
+			counts: nil,
+			want:   []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CumulativeDomainShare(tt.counts)
+			if len(got) != len(tt.want) {
+				t.Fatalf("CumulativeDomainShare() = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("CumulativeDomainShare() = %v, want %v", got, tt.want)
+				}
+				if i > 0 && got[i] < got[i-1] {
+					t.Errorf("CumulativeDomainShare() decreases at %d: %v", i, got)
+				}
+			}
+		})
+	}
+}